@@ -0,0 +1,10 @@
+//go:build go1.22
+// +build go1.22
+
+// The type checker only records type aliases, which mocks render by their
+// declared name, with gotypesalias=1. Its default depends on the go version
+// of go.mod before go1.23, and is off in go1.22.
+
+//go:debug gotypesalias=1
+
+package main
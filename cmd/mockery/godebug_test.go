@@ -0,0 +1,26 @@
+//go:build go1.22
+// +build go1.22
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeAliasesRecorded(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "alias.go", "package p\n\ntype ID = string\n", 0)
+	require.NoError(t, err)
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{file}, nil)
+	require.NoError(t, err)
+
+	_, ok := pkg.Scope().Lookup("ID").Type().(*types.Alias)
+	assert.True(t, ok, "Aliases are only rendered by name when the type checker records them.")
+}
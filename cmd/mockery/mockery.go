@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"go/token"
+	"log"
 	"os"
+	"os/signal"
 	"regexp"
 	"runtime/pprof"
 	"strings"
@@ -14,23 +18,68 @@ import (
 
 const regexMetadataChars = "\\.+*?()|[]{}^$"
 
+// mirrorPackageName is the default -outpkg of -mirror, so that mocks of
+// different packages don't share a package name.
+const mirrorPackageName = "{{.PackageName}}mocks"
+
+// stdout is where reports go, even when -quiet replaces os.Stdout.
+var stdout = os.Stdout
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 type Config struct {
-	fName      string
-	fPrint     bool
-	fOutput    string
-	fOutpkg    string
-	fDir       string
-	fRecursive bool
-	fAll       bool
-	fIP        bool
-	fTO        bool
-	fCase      string
-	fNote      string
-	fProfile   string
-	fVersion   bool
-	quiet      bool
-	fkeepTree  bool
-	buildTags  string
+	fName          string
+	fPrint         bool
+	fOutput        string
+	fOutpkg        string
+	fDir           string
+	fDirs          stringList
+	fRecursive     bool
+	fAll           bool
+	fIP            bool
+	fTO            bool
+	fCase          string
+	fNote          string
+	fProfile       string
+	fVersion       bool
+	quiet          bool
+	fkeepTree      bool
+	fMirror        bool
+	buildTags      string
+	fExpandAliases bool
+	fKeepGoing     bool
+	fCheck         bool
+	fPrune         bool
+	fDryRun        bool
+	fCacheDir      string
+	fConfig        string
+	fExclude       stringList
+	fSrcPkg        string
+	fJobs          int
+	fIncludeTests  bool
+	fPackageFiles  bool
+	fFileName      string
+	fDisambiguate  string
+	fMockName      string
+	fInclude       stringList
+	fReport        string
+	fReportFile    string
+	fRemove        bool
+	fWatch         bool
+	// Run the directives command rather than generate mocks
+	directives bool
+	// The flags given on the command line, by name
+	setFlags map[string]bool
 }
 
 func main() {
@@ -42,7 +91,10 @@ func main() {
 
 	var recursive bool
 	var filter *regexp.Regexp
-	var limitOne bool
+	var names []mockery.InterfaceName
+	var namesFallback *regexp.Regexp
+	// Select the interfaces the configuration file lists
+	var configured bool
 
 	if config.quiet {
 		// if "quiet" flag is set, set os.Stdout to /dev/null to suppress all output to Stdout
@@ -57,26 +109,107 @@ func main() {
 		os.Exit(1)
 	} else if config.fName != "" {
 		recursive = config.fRecursive
-		if strings.ContainsAny(config.fName, regexMetadataChars) {
-			if filter, err = regexp.Compile(config.fName); err != nil {
-				fmt.Fprintln(os.Stderr, "Invalid regular expression provided to -name")
-				os.Exit(1)
-			}
-		} else {
-			filter = regexp.MustCompile(fmt.Sprintf("^%s$", config.fName))
-			limitOne = true
+		var err error
+		if names, namesFallback, filter, err = parseName(config.fName); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -name: %s\n", err)
+			os.Exit(1)
 		}
 	} else if config.fAll {
 		recursive = true
 		filter = regexp.MustCompile(".*")
+	} else if config.directives {
+		recursive = true
+		filter = regexp.MustCompile(".*")
+		configured = true
 	} else {
 		fmt.Fprintln(os.Stderr, "Use -name to specify the name of the interface or -all for all interfaces found")
 		os.Exit(1)
 	}
 
-	if config.fkeepTree {
+	if config.fkeepTree && config.fMirror {
+		fmt.Fprintln(os.Stderr, "Specify -keeptree or -mirror, but not both")
+		os.Exit(1)
+	}
+
+	// The package may be in GOROOT or the module cache, and isn't under
+	// -dir.
+	if config.fSrcPkg != "" && (config.fIP || config.fkeepTree || config.fIncludeTests) {
+		fmt.Fprintln(os.Stderr, "-srcpkg can't be used with -inpkg, -keeptree or -include-tests")
+		os.Exit(1)
+	}
+
+	if config.fkeepTree || config.fMirror {
 		config.fIP = false
 	}
+	if config.fMirror && !config.setFlags["outpkg"] {
+		config.fOutpkg = mirrorPackageName
+	}
+
+	if config.fkeepTree && len(config.fDirs) > 1 {
+		fmt.Fprintln(os.Stderr, "-keeptree requires a single -dir")
+		os.Exit(1)
+	}
+
+	if config.fReport != "" && config.fReport != "json" {
+		fmt.Fprintf(os.Stderr, "Unsupported -report format %q, only json is\n", config.fReport)
+		os.Exit(1)
+	} else if config.fReportFile != "" {
+		config.fReport = "json"
+	}
+	if config.fReport != "" && config.fCheck {
+		fmt.Fprintln(os.Stderr, "-report can't be used with -check")
+		os.Exit(1)
+	} else if config.fReport != "" && config.fReportFile == "" && config.fPrint {
+		fmt.Fprintln(os.Stderr, "-report writes to stdout like -print, use -report-file")
+		os.Exit(1)
+	}
+
+	if config.fCheck && config.fPrint {
+		fmt.Fprintln(os.Stderr, "Specify -check or -print, but not both")
+		os.Exit(1)
+	}
+
+	// Mocks of the interfaces left out would be taken for orphans.
+	partial := config.fSrcPkg != "" || len(config.fInclude) > 0 || len(config.fExclude) > 0
+	if config.fPrune && (!config.fAll || partial) {
+		fmt.Fprintln(os.Stderr, "-prune requires -all without -srcpkg, -include or -exclude, as mocks of other interfaces would be deleted")
+		os.Exit(1)
+	} else if config.fPrune && (config.fCheck || config.fPrint) {
+		fmt.Fprintln(os.Stderr, "-prune can't be used with -check or -print")
+		os.Exit(1)
+	} else if config.fDryRun && !config.fPrune && !config.directives {
+		fmt.Fprintln(os.Stderr, "-dry-run is only supported with -prune and mockery directives")
+		os.Exit(1)
+	}
+
+	if config.directives && (config.fPrint || config.fCheck || config.fPrune || config.fPackageFiles || config.fReport != "") {
+		fmt.Fprintln(os.Stderr, "mockery directives can't be used with -print, -check, -prune, -package-files or -report")
+		os.Exit(1)
+	} else if config.fWatch && (config.directives || config.fPrint || config.fCheck || config.fPrune || config.fDryRun || config.fReport != "" || config.fSrcPkg != "") {
+		fmt.Fprintln(os.Stderr, "-watch can't be used with mockery directives, -print, -check, -prune, -dry-run, -report or -srcpkg")
+		os.Exit(1)
+	} else if config.fRemove && !config.directives {
+		fmt.Fprintln(os.Stderr, "-remove is only supported with mockery directives")
+		os.Exit(1)
+	}
+
+	for _, name := range []string{config.fFileName, config.fMockName, config.fOutpkg} {
+		if err := mockery.CheckNameTemplate(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	exclude, err := parsePatterns(config.fExclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -exclude: %s\n", err)
+		os.Exit(1)
+	}
+	include, err := parsePatterns(config.fInclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -include: %s\n", err)
+		os.Exit(1)
+	}
 
 	if config.fProfile != "" {
 		f, err := os.Create(config.fProfile)
@@ -103,53 +236,361 @@ func main() {
 			Case:                      config.fCase,
 			KeepTree:                  config.fkeepTree,
 			KeepTreeOriginalDirectory: config.fDir,
+			Mirror:                    config.fMirror,
+			FileName:                  config.fFileName,
 		}
 	}
 
-	visitor := &mockery.GeneratorVisitor{
-		InPackage:   config.fIP,
-		Note:        config.fNote,
-		Osp:         osp,
-		PackageName: config.fOutpkg,
+	// A report written to stdout leaves it to the report alone.
+	logger := log.New(os.Stdout, "", 0)
+	if config.fReport != "" && config.fReportFile == "" {
+		logger.SetOutput(os.Stderr)
+	}
+	var report *mockery.Report
+	if config.fReport != "" {
+		report = &mockery.Report{}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := mockery.Options{
+		Dir:           config.fDir,
+		Dirs:          config.fDirs,
+		Recursive:     recursive,
+		SrcPkg:        config.fSrcPkg,
+		Filter:        filter,
+		Names:         names,
+		NamesFallback: namesFallback,
+		BuildTags:     strings.Split(config.buildTags, " "),
+		KeepGoing:     config.fKeepGoing,
+		Exclude:       exclude,
+		Include:       include,
+		Jobs:          config.fJobs,
+		IncludeTests:  config.fIncludeTests,
+		InPackage:     config.fIP,
+		Note:          config.fNote,
+		PackageName:   config.fOutpkg,
+		ExpandAliases: config.fExpandAliases,
+		MockName:      config.fMockName,
+		CacheDir:      config.fCacheDir,
+		PackageFiles:  config.fPackageFiles,
+		Disambiguate:  config.fDisambiguate,
+		Osp:           osp,
+		DryRun:        config.fDryRun,
+		Logger:        logger,
+		Report:        report,
+	}
+
+	if err := loadConfig(&opts, config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	walker := mockery.Walker{
-		BaseDir:   config.fDir,
-		Recursive: recursive,
-		Filter:    filter,
-		LimitOne:  limitOne,
-		BuildTags: strings.Split(config.buildTags, " "),
+	if config.fCheck {
+		check(ctx, opts, config.fAll && !partial)
+		return
+	}
+
+	if config.fWatch {
+		watch(ctx, opts)
+		return
 	}
 
-	generated := walker.Walk(visitor)
+	if config.directives {
+		if configured && opts.Config == nil {
+			fmt.Fprintln(os.Stderr, "Use -name, -all or a configuration file to select the interfaces of mockery directives")
+			os.Exit(1)
+		}
+		directives(ctx, mockery.DirectivesOptions{Options: opts, Remove: config.fRemove, Configured: configured})
+		return
+	}
 
-	if config.fName != "" && !generated {
-		fmt.Printf("Unable to find %s in any go files under this path\n", config.fName)
+	files, err := mockery.Generate(ctx, opts)
+	if report != nil {
+		if err := writeReport(report, config.fReportFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write the report: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+
+	// Missing names were reported by Generate, one by one.
+	if config.fName != "" && (names == nil || namesFallback != nil) && len(files) == 0 {
+		if config.fSrcPkg != "" {
+			fmt.Printf("Unable to find %s in package %s\n", config.fName, config.fSrcPkg)
+		} else {
+			fmt.Printf("Unable to find %s in any go files under this path\n", config.fName)
+		}
+		os.Exit(1)
+	}
+
+	if config.fPrune {
+		// Files is every mock of the run, as errors, including with
+		// -keep-going, exit above.
+		if _, err := mockery.Prune(opts, files); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// check prints a diff for every mock that isn't up to date and exits with a
+// non-zero status if there is any. Orphaned mocks are only looked for with
+// -all and without -srcpkg, -include or -exclude, as other mocks are
+// expected to be found otherwise.
+func check(ctx context.Context, opts mockery.Options, all bool) {
+	mismatches, err := mockery.Check(ctx, opts, all)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, m := range mismatches {
+		fmt.Printf("%s mock: %s\n%s", m.Kind, m.Path, m.Diff)
+	}
+	if len(mismatches) > 0 {
+		fmt.Fprintf(os.Stderr, "%d mock(s) are not up to date\n", len(mismatches))
+		os.Exit(1)
+	}
+}
+
+// writeReport writes report as JSON to path, or to stdout when path is
+// empty.
+func writeReport(report *mockery.Report, path string) error {
+	if path == "" {
+		return report.WriteJSON(stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// watch generates the mocks, and then those whose interface changed every
+// time Go files change, until interrupted. The errors of a round are printed
+// and don't stop it.
+func watch(ctx context.Context, opts mockery.Options) {
+	first := true
+	err := mockery.Watch(ctx, mockery.WatchOptions{
+		Options: opts,
+		OnRound: func(files []mockery.GeneratedFile, err error) {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			if first {
+				fmt.Println("Watching for changes, interrupt to stop")
+				first = false
+			}
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// directives inserts, updates or removes the //go:generate lines of the
+// selected interfaces and prints what changed.
+func directives(ctx context.Context, opts mockery.DirectivesOptions) {
+	changes, err := mockery.UpdateDirectives(ctx, opts)
+	for _, c := range changes {
+		switch {
+		case c.Old == "":
+			fmt.Printf("%s:%d: inserted %s\n", c.Path, c.Line, c.New)
+		case c.New == "":
+			fmt.Printf("%s:%d: removed %s\n", c.Path, c.Line, c.Old)
+		default:
+			fmt.Printf("%s:%d: updated %s\n", c.Path, c.Line, c.New)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// parseNames parses a comma-separated list of bare or qualified interface
+// names.
+// parseName tells what -name selects. Names, possibly qualified and
+// separated by commas, must each match a single interface, and anything
+// else, such as Req{1,2}, is a regular expression returned as filter. So is
+// a single name qualified by something no package is named, such as
+// Req.ester, which is also returned as fallback.
+func parseName(value string) (names []mockery.InterfaceName, fallback, filter *regexp.Regexp, err error) {
+	names, err = parseNames(value)
+	if err == nil {
+		return names, nameRegexp(value, names), nil, nil
+	}
+	if !strings.ContainsAny(value, regexMetadataChars) {
+		return nil, nil, nil, err
+	}
+	if filter, err = regexp.Compile(value); err != nil {
+		return nil, nil, nil, err
+	}
+	return nil, nil, filter, nil
+}
+
+func parseNames(value string) ([]mockery.InterfaceName, error) {
+	var names []mockery.InterfaceName
+	for _, s := range strings.Split(value, ",") {
+		name, err := mockery.ParseInterfaceName(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// nameRegexp returns the regular expression value also is when it's a single
+// name qualified by an identifier, such as Req.ester, to be used when no
+// package is named that way.
+func nameRegexp(value string, names []mockery.InterfaceName) *regexp.Regexp {
+	if len(names) != 1 || !token.IsIdentifier(names[0].Qualifier) {
+		return nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+func parsePatterns(values []string) ([]mockery.Pattern, error) {
+	patterns := make([]mockery.Pattern, len(values))
+	for i, value := range values {
+		var err error
+		if patterns[i], err = mockery.ParsePattern(value); err != nil {
+			return nil, err
+		}
+	}
+	return patterns, nil
+}
+
+// loadConfig sets the configuration file given with -config, or found upward
+// from -dir, in opts. Flags given on the command line take precedence over
+// it.
+func loadConfig(opts *mockery.Options, config Config) error {
+	path := config.fConfig
+	if path == "" {
+		var err error
+		if path, err = mockery.FindConfig(config.fDir); err != nil || path == "" {
+			return err
+		}
+	}
+
+	cfg, err := mockery.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	opts.Config = cfg
+	opts.Overrides = config.overrides()
+	return nil
+}
+
+// overrides returns the settings given on the command line.
+func (c Config) overrides() mockery.Settings {
+	var s mockery.Settings
+	if c.setFlags["output"] {
+		s.Output = &c.fOutput
+	}
+	if c.setFlags["outpkg"] {
+		s.Outpkg = &c.fOutpkg
+	}
+	if c.setFlags["inpkg"] || c.fkeepTree || c.fMirror {
+		s.InPkg = &c.fIP
+	}
+	if c.setFlags["testonly"] {
+		s.TestOnly = &c.fTO
+	}
+	if c.setFlags["case"] {
+		s.Case = &c.fCase
+	}
+	if c.setFlags["note"] {
+		s.Note = &c.fNote
+	}
+	if c.setFlags["expandaliases"] {
+		s.ExpandAliases = &c.fExpandAliases
+	}
+	if c.setFlags["filename"] {
+		s.FileName = &c.fFileName
+	}
+	if c.setFlags["mockname"] {
+		s.MockName = &c.fMockName
+	}
+	return s
 }
 
 func parseConfigFromArgs(args []string) (Config, error) {
 	config := Config{}
+	if len(args) > 1 && args[1] == "directives" {
+		config.directives = true
+		args = append([]string{args[0]}, args[2:]...)
+	}
 
 	flagSet := flag.NewFlagSet(args[0], flag.ExitOnError)
 
-	flagSet.StringVar(&config.fName, "name", "", "name or matching regular expression of interface to generate mock for")
+	flagSet.StringVar(&config.fName, "name", "", "comma-separated names of interfaces to generate mocks for, each optionally qualified by package name or import path (store.Store) and matching a single interface, or regular expression matching the interfaces")
 	flagSet.BoolVar(&config.fPrint, "print", false, "print the generated mock to stdout")
 	flagSet.StringVar(&config.fOutput, "output", "./mocks", "directory to write mocks to")
-	flagSet.StringVar(&config.fOutpkg, "outpkg", "mocks", "name of generated package")
-	flagSet.StringVar(&config.fDir, "dir", ".", "directory to search for interfaces")
+	flagSet.StringVar(&config.fOutpkg, "outpkg", "mocks", "name of generated package, or a template such as {{.PackageName}}mocks")
+	flagSet.Var(&config.fDirs, "dir", "directory to search for interfaces, defaults to the current one; can be repeated")
+	flagSet.StringVar(&config.fSrcPkg, "srcpkg", "", "import path of a package to search for interfaces instead of -dir, e.g. net/http")
 	flagSet.BoolVar(&config.fRecursive, "recursive", false, "recurse search into sub-directories")
 	flagSet.BoolVar(&config.fAll, "all", false, "generates mocks for all found interfaces in all sub-directories")
 	flagSet.BoolVar(&config.fIP, "inpkg", false, "generate a mock that goes inside the original package")
 	flagSet.BoolVar(&config.fTO, "testonly", false, "generate a mock in a _test.go file")
 	flagSet.StringVar(&config.fCase, "case", "camel", "name the mocked file using casing convention [camel, snake, underscore]")
+	flagSet.StringVar(&config.fFileName, "filename", "", "template naming the mocked files instead of -case, e.g. {{.InterfaceName | snakecase}}_mock.go")
+	flagSet.StringVar(&config.fMockName, "mockname", "", "template naming the mock types, e.g. Mock{{.InterfaceName | firstUpper}}")
 	flagSet.StringVar(&config.fNote, "note", "", "comment to insert into prologue of each generated file")
 	flagSet.StringVar(&config.fProfile, "cpuprofile", "", "write cpu profile to file")
 	flagSet.BoolVar(&config.fVersion, "version", false, "prints the installed version of mockery")
 	flagSet.BoolVar(&config.quiet, "quiet", false, "suppress output to stdout")
 	flagSet.BoolVar(&config.fkeepTree, "keeptree", false, "keep the tree structure of the original interface files into a different repository. Must be used with XX")
+	flagSet.BoolVar(&config.fMirror, "mirror", false, "write mocks to -output followed by the import path of their package within the module, in a package named "+mirrorPackageName+" unless -outpkg is given")
 	flagSet.StringVar(&config.buildTags, "tags", "", "space-separated list of additional build tags to use")
+	flagSet.BoolVar(&config.fCheck, "check", false, "check that the mocks on disk are up to date instead of writing them")
+	flagSet.BoolVar(&config.fPrune, "prune", false, "delete the mocks generated by mockery that no interface produces anymore, requires -all without -srcpkg, -include or -exclude")
+	flagSet.BoolVar(&config.fDryRun, "dry-run", false, "with -prune, list the mocks that would be deleted without writing or deleting anything; with mockery directives, list the changes without writing them")
+	flagSet.BoolVar(&config.fRemove, "remove", false, "with mockery directives, remove the //go:generate lines of the selected interfaces instead of inserting or updating them")
+	flagSet.StringVar(&config.fCacheDir, "cache", "", "directory to cache generated mocks in; mocks whose interface didn't change are skipped")
+	flagSet.BoolVar(&config.fIncludeTests, "include-tests", false, "also generate mocks for the interfaces declared in _test.go files, in _test.go files of the same package")
+	flagSet.BoolVar(&config.fPackageFiles, "package-files", false, "write the mocks of each source package into a single file, <package>_mocks.go, instead of one file per interface")
+	flagSet.StringVar(&config.fDisambiguate, "disambiguate", "", "tell apart the mocks of same-named interfaces of different packages [prefix, subdir]; they fail the run otherwise")
+	flagSet.BoolVar(&config.fWatch, "watch", false, "keep running and generate the mocks whose interface changed again whenever Go files change")
+	flagSet.IntVar(&config.fJobs, "jobs", 0, "number of mocks to generate at once, defaults to GOMAXPROCS")
+	flagSet.BoolVar(&config.fKeepGoing, "keep-going", false, "keep generating the remaining mocks after an error; the run still fails. Without it, mocks other -jobs were already generating are still written")
+	flagSet.BoolVar(&config.fExpandAliases, "expandaliases", false, "render type aliases as the type they stand for instead of by name")
+	flagSet.Var(&config.fExclude, "exclude", "skip the directories and interfaces matching this glob, or regular expression prefixed with re:; a glob without a slash matches the last element of paths and qualified names, or interface names, e.g. gen, store.Store or Store; can be repeated")
+	flagSet.Var(&config.fInclude, "include", "only generate mocks for the directories and interfaces matching this glob, or regular expression prefixed with re:; a glob without a slash matches the last element of paths and qualified names, or interface names, e.g. gen, store.Store or Store; can be repeated")
+	flagSet.StringVar(&config.fReport, "report", "", "print a report of the mock generated for each interface to stdout, in the given format [json]")
+	flagSet.StringVar(&config.fReportFile, "report-file", "", "write the json report of the mock generated for each interface to this file")
+	flagSet.StringVar(&config.fConfig, "config", "", "configuration file to use instead of the "+mockery.ConfigFileName+" found in -dir or its parents")
 
-	return config, flagSet.Parse(args[1:])
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return config, err
+	}
+
+	config.setFlags = make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		config.setFlags[f.Name] = true
+	})
+
+	config.fDir = "."
+	if len(config.fDirs) > 0 {
+		config.fDir = config.fDirs[0]
+	}
+	return config, nil
 }
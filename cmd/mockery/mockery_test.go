@@ -24,10 +24,11 @@ func TestParseConfigDefaults(t *testing.T) {
 	assert.Equal(t, false, config.fTO)
 	assert.Equal(t, "camel", config.fCase)
 	assert.Equal(t, "", config.fNote)
+	assert.Equal(t, false, config.fExpandAliases)
 }
 
 func TestParseConfigFlippingValues(t *testing.T) {
-	config, err := configFromCommandLine("mockery -name hi -print -output output -dir dir -recursive -all -inpkg -testonly -case case -note note")
+	config, err := configFromCommandLine("mockery -name hi -print -output output -dir dir -recursive -all -inpkg -testonly -case case -note note -expandaliases")
 	assert.NoError(t, err)
 	assert.Equal(t, "hi", config.fName)
 	assert.Equal(t, true, config.fPrint)
@@ -39,4 +40,92 @@ func TestParseConfigFlippingValues(t *testing.T) {
 	assert.Equal(t, true, config.fTO)
 	assert.Equal(t, "case", config.fCase)
 	assert.Equal(t, "note", config.fNote)
+	assert.Equal(t, true, config.fExpandAliases)
+}
+
+func TestParseConfigOverrides(t *testing.T) {
+	config, err := configFromCommandLine("mockery -all -outpkg fakes -case snake")
+	assert.NoError(t, err)
+
+	overrides := config.overrides()
+	assert.Equal(t, "fakes", *overrides.Outpkg)
+	assert.Equal(t, "snake", *overrides.Case)
+	assert.Nil(t, overrides.Output, "Flags left to their default don't override the configuration.")
+	assert.Nil(t, overrides.InPkg)
+}
+
+func TestParseConfigRepeatedDir(t *testing.T) {
+	config, err := configFromCommandLine("mockery -name a.Store,c.Store -dir a -dir c")
+	assert.NoError(t, err)
+	assert.Equal(t, stringList{"a", "c"}, config.fDirs)
+	assert.Equal(t, "a", config.fDir, "The first directory is the one configuration is looked up from.")
+}
+
+func TestParseNames(t *testing.T) {
+	names, err := parseNames("Requester, store.Store")
+	assert.NoError(t, err)
+	if assert.Len(t, names, 2) {
+		assert.Equal(t, "Requester", names[0].String())
+		assert.Equal(t, "store.Store", names[1].String())
+	}
+
+	_, err = parseNames("Requester,Req.*")
+	assert.Error(t, err)
+}
+
+func TestParseName(t *testing.T) {
+	names, fallback, filter, err := parseName("Requester,store.Store")
+	assert.NoError(t, err)
+	assert.Len(t, names, 2)
+	assert.Nil(t, fallback)
+	assert.Nil(t, filter)
+
+	for _, value := range []string{"Req{1,2}", "(A|B){1,3}", "Requester,Req.*"} {
+		names, _, filter, err = parseName(value)
+		assert.NoError(t, err, value)
+		assert.Nil(t, names, value)
+		if assert.NotNil(t, filter, value) {
+			assert.Equal(t, value, filter.String(), "Values with commas that aren't all names are regular expressions.")
+		}
+	}
+	assert.True(t, filter.MatchString("Requester,Requester"))
+
+	_, _, _, err = parseName("Requester,store-Store")
+	assert.Error(t, err)
+	_, _, _, err = parseName("Req(")
+	assert.Error(t, err)
+}
+
+func TestNameRegexp(t *testing.T) {
+	names, err := parseNames("Req.ester")
+	assert.NoError(t, err)
+	if re := nameRegexp("Req.ester", names); assert.NotNil(t, re, "Dotted regular expressions may be qualified names.") {
+		assert.True(t, re.MatchString("Requester"))
+	}
+
+	names, err = parseNames("svc/store.Store")
+	assert.NoError(t, err)
+	assert.Nil(t, nameRegexp("svc/store.Store", names))
+
+	names, err = parseNames("Requester,store.Store")
+	assert.NoError(t, err)
+	assert.Nil(t, nameRegexp("Requester,store.Store", names))
+}
+
+func TestParseConfigDirectives(t *testing.T) {
+	config, err := configFromCommandLine("mockery directives -all -remove")
+	assert.NoError(t, err)
+	assert.True(t, config.directives)
+	assert.True(t, config.fAll)
+	assert.True(t, config.fRemove)
+
+	config, err = configFromCommandLine("mockery -all")
+	assert.NoError(t, err)
+	assert.False(t, config.directives)
+}
+
+func TestParseConfigWatch(t *testing.T) {
+	config, err := configFromCommandLine("mockery -all -watch")
+	assert.NoError(t, err)
+	assert.True(t, config.fWatch)
 }
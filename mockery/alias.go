@@ -0,0 +1,51 @@
+//go:build go1.22
+// +build go1.22
+
+package mockery
+
+import "go/types"
+
+// unalias returns the type an alias stands for, following chains of
+// aliases. Any other type is returned as is.
+func unalias(typ types.Type) types.Type {
+	return types.Unalias(typ)
+}
+
+// renderAlias renders an alias by the name it was declared with, importing
+// the package that declares it. Aliases that can't be referred to from the
+// mock, such as unexported or instantiated generic aliases, and every alias
+// when ExpandAliases is set, are rendered as the type they stand for.
+func (g *Generator) renderAlias(typ types.Type) (string, bool) {
+	t, ok := typ.(*types.Alias)
+	if !ok {
+		return "", false
+	}
+
+	o := t.Obj()
+	if g.ExpandAliases || hasTypeArgs(t) {
+		return g.renderType(types.Unalias(t)), true
+	}
+	if o.Pkg() == nil || o.Pkg().Name() == "main" || (g.ip && o.Pkg() == g.iface.Pkg) {
+		return o.Name(), true
+	}
+	if !o.Exported() {
+		return g.renderType(types.Unalias(t)), true
+	}
+	return g.addPackageImport(o.Pkg()) + "." + o.Name(), true
+}
+
+func hasTypeArgs(t *types.Alias) bool {
+	// TypeArgs was only added in go1.23.
+	targs, ok := interface{}(t).(interface{ TypeArgs() *types.TypeList })
+	return ok && targs.TypeArgs().Len() > 0
+}
+
+// aliasTarget returns the type an alias stands for, or false when typ isn't
+// an alias.
+func aliasTarget(typ types.Type) (types.Type, bool) {
+	t, ok := typ.(*types.Alias)
+	if !ok {
+		return nil, false
+	}
+	return types.Unalias(t), true
+}
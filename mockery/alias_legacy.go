@@ -0,0 +1,21 @@
+//go:build !go1.22
+// +build !go1.22
+
+package mockery
+
+import "go/types"
+
+// Before go1.22 the type checker always resolves aliases to the type they
+// stand for, so there is nothing to preserve.
+
+func unalias(typ types.Type) types.Type {
+	return typ
+}
+
+func (g *Generator) renderAlias(typ types.Type) (string, bool) {
+	return "", false
+}
+
+func aliasTarget(typ types.Type) (types.Type, bool) {
+	return nil, false
+}
@@ -0,0 +1,69 @@
+//go:build go1.22
+// +build go1.22
+
+package mockery
+
+func (s *GeneratorSuite) TestGeneratorForAlias() {
+	expected := `// RequesterAlias is an autogenerated mock type for the RequesterAlias type
+type RequesterAlias struct {
+	mock.Mock
+}
+
+type RequesterAliasExpectation struct {
+	mock *mock.Mock
+}
+
+func (_m *RequesterAlias) Expect() *RequesterAliasExpectation {
+	return &RequesterAliasExpectation{mock: &_m.Mock}
+}
+
+// Get provides a mock function with given fields: id, ids
+func (_m *RequesterAlias) Get(id test.ID, ids test.IDs) (http.MyStructAlias, error) {
+	ret := _m.Called(id, ids)
+
+	var r0 http.MyStructAlias
+	if rf, ok := ret.Get(0).(func(test.ID, test.IDs) http.MyStructAlias); ok {
+		r0 = rf(id, ids)
+	} else {
+		r0 = ret.Get(0).(http.MyStructAlias)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(test.ID, test.IDs) error); ok {
+		r1 = rf(id, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type RequesterAliasGetExpectation struct {
+	call *mock.Call
+}
+
+func (_e *RequesterAliasExpectation) Get(id test.ID, ids test.IDs) *RequesterAliasGetExpectation {
+	return &RequesterAliasGetExpectation{
+		call: _e.mock.On("Get", id, ids),
+	}
+}
+
+func (_e *RequesterAliasGetExpectation) ToReturn(_a0 http.MyStructAlias, _a1 error) *mock.Call {
+	return _e.call.Return(_a0, _a1)
+}
+`
+	s.checkGeneration(getFixturePath("alias.go"), "RequesterAlias", false, expected)
+}
+
+func (s *GeneratorSuite) TestGeneratorForAliasInPackage() {
+	gen := s.getGenerator("alias.go", "RequesterAlias", true)
+	s.NoError(gen.Generate())
+	s.Contains(gen.buf.String(), "func (_m *MockRequesterAlias) Get(id ID, ids IDs) (http.MyStructAlias, error) {")
+}
+
+func (s *GeneratorSuite) TestGeneratorForAliasExpanded() {
+	gen := s.getGenerator("alias.go", "RequesterAlias", false)
+	gen.ExpandAliases = true
+	s.NoError(gen.Generate())
+	s.Contains(gen.buf.String(), "func (_m *RequesterAlias) Get(id string, ids []string) (http.MyStruct, error) {")
+}
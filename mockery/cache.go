@@ -0,0 +1,183 @@
+package mockery
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fingerprintHeader starts the line of the prologue holding the fingerprint
+// of a mock, see GeneratePrologueNote.
+const fingerprintHeader = "// Fingerprint: "
+
+// fingerprint hashes everything the mock of iface is generated from: the
+// mockery version, the options of the visitor and the full method set of
+// iface, along with the source file's import aliases, the names of the
+// packages it refers to, the targets of the type aliases it refers to and
+// which of its parameters and results can be nil.
+func (this *GeneratorVisitor) fingerprint(iface *Interface) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "mockery %s\n", SemVer)
+	fmt.Fprintf(h, "inpkg=%t note=%q outpkg=%q expandaliases=%t mockname=%q prefix=%q\n", this.InPackage, this.Note, this.PackageName, this.ExpandAliases, this.MockName, this.NamePrefix)
+	fmt.Fprintf(h, "package %s %q\ninterface %s\n", iface.Pkg.Name(), iface.QualifiedName, iface.Name)
+	fmt.Fprintf(h, "directives %+v\n", iface.Directives)
+
+	aliases := sourceImportAliases(iface)
+	paths := make([]string, 0, len(aliases))
+	for path := range aliases {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "import %s %q\n", aliases[path], path)
+	}
+
+	// Mocks refer to packages by name, which may change while their path
+	// stays the same.
+	qualifier := func(pkg *types.Package) string { return fmt.Sprintf("%s %q", pkg.Name(), pkg.Path()) }
+	seen := make(map[types.Type]bool)
+	for i := 0; i < iface.Type.NumMethods(); i++ {
+		fn := iface.Type.Method(i)
+		fmt.Fprintf(h, "func %s%s\n", fn.Name(), types.TypeString(fn.Type(), qualifier)[len("func"):])
+		writeAliases(h, fn.Type(), qualifier, seen)
+		sig := fn.Type().(*types.Signature)
+		writeNillable(h, "params", sig.Params())
+		writeNillable(h, "results", sig.Results())
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// packageFingerprint hashes the fingerprints of ifaces, whose mocks share a
+// file, in order.
+func (this *GeneratorVisitor) packageFingerprint(ifaces []*Interface) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "package file of %d mocks\n", len(ifaces))
+	for _, iface := range ifaces {
+		fmt.Fprintf(h, "%s\n", this.fingerprint(iface))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeAliases writes the type every alias reachable from typ stands for,
+// which types.TypeString doesn't show but mocks may render. Named types are
+// rendered by name so their underlying type isn't followed.
+func writeAliases(h hash.Hash, typ types.Type, qualifier types.Qualifier, seen map[types.Type]bool) {
+	if seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	if target, ok := aliasTarget(typ); ok {
+		fmt.Fprintf(h, "alias %s = %s\n", types.TypeString(typ, qualifier), types.TypeString(target, qualifier))
+		writeAliases(h, target, qualifier, seen)
+		return
+	}
+
+	switch t := typ.(type) {
+	case *types.Pointer:
+		writeAliases(h, t.Elem(), qualifier, seen)
+	case *types.Slice:
+		writeAliases(h, t.Elem(), qualifier, seen)
+	case *types.Array:
+		writeAliases(h, t.Elem(), qualifier, seen)
+	case *types.Chan:
+		writeAliases(h, t.Elem(), qualifier, seen)
+	case *types.Map:
+		writeAliases(h, t.Key(), qualifier, seen)
+		writeAliases(h, t.Elem(), qualifier, seen)
+	case *types.Signature:
+		writeAliases(h, t.Params(), qualifier, seen)
+		writeAliases(h, t.Results(), qualifier, seen)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			writeAliases(h, t.At(i).Type(), qualifier, seen)
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			writeAliases(h, t.Field(i).Type(), qualifier, seen)
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			writeAliases(h, t.Method(i).Type(), qualifier, seen)
+		}
+	}
+}
+
+// writeNillable writes which of the types of tup can be nil, which mocks
+// check before converting values but types.TypeString doesn't show for
+// named types.
+func writeNillable(h hash.Hash, kind string, tup *types.Tuple) {
+	fmt.Fprintf(h, "nillable %s", kind)
+	for i := 0; i < tup.Len(); i++ {
+		fmt.Fprintf(h, " %t", isNillable(tup.At(i).Type()))
+	}
+	fmt.Fprintln(h)
+}
+
+// readFingerprint returns the fingerprint in the prologue of a generated
+// mock, or an empty string when it has none.
+func readFingerprint(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, fingerprintHeader) {
+			return strings.TrimPrefix(line, fingerprintHeader)
+		}
+		if line != "" && !strings.HasPrefix(line, "//") {
+			break
+		}
+	}
+	return ""
+}
+
+// cache stores generated mocks on disk by fingerprint.
+type cache struct {
+	dir string
+}
+
+func (c cache) path(fingerprint string) string {
+	return filepath.Join(c.dir, fingerprint[:2], fingerprint)
+}
+
+// get returns the mock with the given fingerprint, if it was stored.
+func (c cache) get(fingerprint string) ([]byte, bool) {
+	content, err := ioutil.ReadFile(c.path(fingerprint))
+	if err != nil || readFingerprint(content) != fingerprint {
+		return nil, false
+	}
+	return content, true
+}
+
+// put stores a mock by its fingerprint. The mock is written to a temporary
+// file first so that concurrent runs never read a partial mock.
+func (c cache) put(fingerprint string, content []byte) error {
+	path := c.path(fingerprint)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), fingerprint)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,149 @@
+package mockery
+
+import (
+	"context"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	parser := NewParser(nil)
+	require.NoError(t, parser.Parse(testFile))
+	require.NoError(t, parser.Parse(testFile2))
+	require.NoError(t, parser.Load())
+
+	requester, err := parser.Find("Requester")
+	require.NoError(t, err)
+	requester2, err := parser.Find("Requester2")
+	require.NoError(t, err)
+
+	visitor := &GeneratorVisitor{PackageName: "mocks"}
+	fingerprint := visitor.fingerprint(requester)
+	assert.Len(t, fingerprint, 64)
+	assert.Equal(t, fingerprint, visitor.fingerprint(requester))
+	assert.NotEqual(t, fingerprint, visitor.fingerprint(requester2))
+
+	visitor.Note = "note"
+	assert.NotEqual(t, fingerprint, visitor.fingerprint(requester), "Options are part of the fingerprint.")
+}
+
+func TestFingerprintPackageNames(t *testing.T) {
+	dep := types.NewPackage("example.com/dep", "dep")
+	named := types.NewNamed(types.NewTypeName(token.NoPos, dep, "Event", nil), types.Typ[types.String], nil)
+	params := types.NewTuple(types.NewVar(token.NoPos, nil, "event", named))
+	method := types.NewFunc(token.NoPos, nil, "Publish", types.NewSignature(nil, params, nil, false))
+
+	pkg := types.NewPackage("example.com/bus", "bus")
+	iface := &Interface{
+		Name:          "Publisher",
+		QualifiedName: pkg.Path(),
+		Pkg:           pkg,
+		Type:          types.NewInterfaceType([]*types.Func{method}, nil).Complete(),
+	}
+
+	visitor := &GeneratorVisitor{PackageName: "mocks"}
+	fingerprint := visitor.fingerprint(iface)
+	dep.SetName("events")
+	assert.NotEqual(t, fingerprint, visitor.fingerprint(iface), "Mocks refer to packages by name.")
+}
+
+func TestFingerprintUnderlyingTypes(t *testing.T) {
+	pkg := types.NewPackage("example.com/store", "store")
+	getter := func(underlying types.Type) *Interface {
+		named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "R", nil), underlying, nil)
+		results := types.NewTuple(types.NewVar(token.NoPos, nil, "", named))
+		method := types.NewFunc(token.NoPos, nil, "Get", types.NewSignature(nil, nil, results, false))
+		return &Interface{
+			Name:          "Getter",
+			QualifiedName: pkg.Path(),
+			Pkg:           pkg,
+			Type:          types.NewInterfaceType([]*types.Func{method}, nil).Complete(),
+		}
+	}
+
+	visitor := &GeneratorVisitor{PackageName: "mocks"}
+	fingerprint := visitor.fingerprint(getter(types.NewStruct(nil, nil)))
+	assert.Equal(t, fingerprint, visitor.fingerprint(getter(types.Typ[types.Int])))
+	assert.NotEqual(t, fingerprint, visitor.fingerprint(getter(types.NewMap(types.Typ[types.String], types.Typ[types.Int]))), "Mocks check whether results can be nil.")
+}
+
+func TestReadFingerprint(t *testing.T) {
+	assert.Equal(t, "abc", readFingerprint([]byte(generatedHeader+" v1. DO NOT EDIT.\n"+fingerprintHeader+"abc\n\npackage mocks\n")))
+	assert.Equal(t, "", readFingerprint([]byte(generatedHeader+" v1. DO NOT EDIT.\n\npackage mocks\n"+fingerprintHeader+"abc\n")))
+}
+
+func TestGenerateWithCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	opts := Options{
+		Dir:      fixturePath,
+		Filter:   regexp.MustCompile("^Requester$"),
+		CacheDir: filepath.Join(dir, "cache"),
+		Osp:      &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+	}
+
+	files, err := Generate(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.False(t, files[0].Unchanged)
+
+	fingerprint := readFingerprint(files[0].Content)
+	assert.NotEmpty(t, fingerprint)
+	assert.True(t, strings.HasPrefix(string(files[0].Content), generatedHeader))
+
+	files, err = Generate(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, files[0].Unchanged, "The mock on disk has the same fingerprint.")
+
+	// A cached mock is written as is, without generating it.
+	cached := []byte(generatedHeader + " v0.0.0. DO NOT EDIT.\n" + fingerprintHeader + fingerprint + "\n\npackage cached\n")
+	require.NoError(t, cache{dir: opts.CacheDir}.put(fingerprint, cached))
+	require.NoError(t, os.Remove(files[0].Path))
+
+	files, err = Generate(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.False(t, files[0].Unchanged)
+	written, err := ioutil.ReadFile(files[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, string(cached), string(written))
+}
+
+func TestGenerateWithCacheUnderlyingType(t *testing.T) {
+	dir := tempModule(t, map[string]string{
+		"store/store.go": "package store\n\ntype R struct{}\n\ntype Getter interface {\n\tGet() R\n}\n",
+	})
+
+	ctx := context.Background()
+	opts := Options{
+		Dir:      filepath.Join(dir, "store"),
+		Filter:   regexp.MustCompile("^Getter$"),
+		CacheDir: filepath.Join(dir, "cache"),
+		Osp:      &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+	}
+
+	files, err := Generate(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.NotContains(t, string(files[0].Content), "if ret.Get(0) != nil")
+
+	writeTempFile(t, filepath.Join(dir, "store", "store.go"), "package store\n\ntype R map[string]int\n\ntype Getter interface {\n\tGet() R\n}\n")
+	files, err = Generate(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.False(t, files[0].Unchanged, "Mocks depend on whether the types they return can be nil.")
+	assert.Contains(t, string(files[0].Content), "if ret.Get(0) != nil")
+}
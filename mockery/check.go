@@ -0,0 +1,245 @@
+package mockery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// generatedHeader starts the first line of every mock, see
+// GeneratePrologueNote.
+const generatedHeader = "// Code generated by mockery"
+
+// MismatchKind tells how a mock on disk differs from the generated one.
+type MismatchKind string
+
+const (
+	// Stale mocks differ from the generated ones.
+	Stale MismatchKind = "stale"
+	// Missing mocks are generated but not on disk.
+	Missing MismatchKind = "missing"
+	// Extra mocks are on disk but no longer generated.
+	Extra MismatchKind = "extra"
+)
+
+// Mismatch is a mock on disk that isn't up to date.
+type Mismatch struct {
+	Kind MismatchKind
+	Path string
+	// Unified diff from the file on disk to the generated mock
+	Diff string
+}
+
+// ErrNoOutputFiles is returned when checking mocks that aren't written to
+// files.
+var ErrNoOutputFiles = errors.New("mocks are not written to files")
+
+// ErrPartialRun is returned when looking for orphaned mocks after a run that
+// leaves interfaces out with SrcPkg, Names, Include or Exclude, as their
+// mocks would be taken for orphans.
+var ErrPartialRun = errors.New("orphaned mocks can't be looked for when SrcPkg, Names, Include or Exclude leave interfaces out")
+
+// Check generates mocks like Generate, without writing anything, and
+// compares them with the files opts.Osp would write. When extra is set,
+// files generated by mockery in the output directories that the run doesn't
+// produce are reported too; leave it unset when only some of the interfaces
+// are generated, ErrPartialRun is returned if opts leave some out.
+func Check(ctx context.Context, opts Options, extra bool) ([]Mismatch, error) {
+	if _, ok := opts.Osp.(pathProvider); !ok {
+		return nil, ErrNoOutputFiles
+	}
+
+	// The cache would report mocks with an up to date fingerprint as is,
+	// even when they were edited since.
+	opts.DryRun, opts.CacheDir = true, ""
+	files, err := Generate(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []Mismatch
+	for _, file := range files {
+		existing, err := ioutil.ReadFile(file.Path)
+		if os.IsNotExist(err) {
+			mismatches = append(mismatches, Mismatch{
+				Kind: Missing,
+				Path: file.Path,
+				Diff: unifiedDiff(nil, file.Content, os.DevNull, file.Path),
+			})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(existing, file.Content) {
+			mismatches = append(mismatches, Mismatch{
+				Kind: Stale,
+				Path: file.Path,
+				Diff: unifiedDiff(existing, file.Content, file.Path, file.Path),
+			})
+		}
+	}
+
+	if !extra {
+		return mismatches, nil
+	}
+
+	orphans, err := findOrphans(opts, files)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range orphans {
+		existing, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, Mismatch{
+			Kind: Extra,
+			Path: path,
+			Diff: unifiedDiff(existing, nil, path, os.DevNull),
+		})
+	}
+
+	return mismatches, nil
+}
+
+func unifiedDiff(a, b []byte, fromFile, toFile string) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	return diff
+}
+
+// splitLines splits content after each newline. Unlike difflib.SplitLines,
+// it doesn't add an empty line at the end of the content.
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// findOrphans returns the files generated by mockery in the directories
+// mocks are written to that aren't among files, sorted by path.
+func findOrphans(opts Options, files []GeneratedFile) ([]string, error) {
+	if opts.partial() {
+		return nil, ErrPartialRun
+	}
+	fop, ok := opts.Osp.(*FileOutputStreamProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	produced := make(map[string]bool, len(files))
+	for _, file := range files {
+		abs, err := filepath.Abs(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		produced[abs] = true
+	}
+
+	var orphans []string
+	find := func(root string, recursive bool) error {
+		more, err := findGeneratedFiles(root, recursive, produced)
+		orphans = append(orphans, more...)
+		return err
+	}
+	for _, root := range opts.outputDirs() {
+		if err := find(root, true); err != nil {
+			return nil, err
+		}
+	}
+	if fop.InPackage {
+		for _, root := range opts.dirs() {
+			if err := find(root, opts.Recursive); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Output directories may be nested.
+	sort.Strings(orphans)
+	unique := orphans[:0]
+	for i, orphan := range orphans {
+		if i == 0 || orphan != orphans[i-1] {
+			unique = append(unique, orphan)
+		}
+	}
+	return unique, nil
+}
+
+// findGeneratedFiles returns the files generated by mockery in root, and its
+// subdirectories when recursive, that aren't produced. Directories the walk
+// always skips, such as testdata, are left alone.
+func findGeneratedFiles(root string, recursive bool, produced map[string]bool) ([]string, error) {
+	var orphans []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if !recursive || skippedDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if produced[abs] {
+			return nil
+		}
+
+		generated, err := isGeneratedByMockery(path)
+		if err != nil {
+			return err
+		}
+		if generated {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// isGeneratedByMockery tells whether the file at path starts with the header
+// written by GeneratePrologueNote.
+func isGeneratedByMockery(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return false, nil
+	}
+	return strings.HasPrefix(line, generatedHeader), nil
+}
@@ -0,0 +1,79 @@
+package mockery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkOptions(dir string) Options {
+	return Options{
+		Dir:    fixturePath,
+		Filter: regexp.MustCompile("^Requester2?$"),
+		Osp:    &FileOutputStreamProvider{BaseDir: dir},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	_, err = Generate(ctx, checkOptions(dir))
+	require.NoError(t, err)
+
+	mismatches, err := Check(ctx, checkOptions(dir), true)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	requester := filepath.Join(dir, "Requester.go")
+	require.NoError(t, ioutil.WriteFile(requester, []byte(generatedHeader+" v0.0.0. DO NOT EDIT.\n"), 0644))
+	requester2 := filepath.Join(dir, "Requester2.go")
+	require.NoError(t, os.Remove(requester2))
+	orphan := filepath.Join(dir, "Deleted.go")
+	require.NoError(t, ioutil.WriteFile(orphan, []byte(generatedHeader+" v0.0.0. DO NOT EDIT.\n\npackage mocks\n"), 0644))
+	handWritten := filepath.Join(dir, "helpers.go")
+	require.NoError(t, ioutil.WriteFile(handWritten, []byte("package mocks\n"), 0644))
+
+	mismatches, err = Check(ctx, checkOptions(dir), true)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 3)
+
+	assert.Equal(t, Stale, mismatches[0].Kind)
+	assert.Equal(t, requester, mismatches[0].Path)
+	assert.Contains(t, mismatches[0].Diff, "--- "+requester+"\n")
+	assert.Contains(t, mismatches[0].Diff, "-// Code generated by mockery v0.0.0. DO NOT EDIT.\n")
+	assert.Contains(t, mismatches[0].Diff, "+type Requester struct {\n")
+
+	assert.Equal(t, Missing, mismatches[1].Kind)
+	assert.Equal(t, requester2, mismatches[1].Path)
+	assert.Contains(t, mismatches[1].Diff, "--- "+os.DevNull+"\n")
+
+	assert.Equal(t, Extra, mismatches[2].Kind)
+	assert.Equal(t, orphan, mismatches[2].Path)
+	assert.Contains(t, mismatches[2].Diff, "+++ "+os.DevNull+"\n")
+
+	mismatches, err = Check(ctx, checkOptions(dir), false)
+	require.NoError(t, err)
+	assert.Len(t, mismatches, 2, "Extra mocks are only looked for on request.")
+
+	partial := checkOptions(dir)
+	partial.Exclude = []Pattern{exactPattern("Requester2")}
+	_, err = Check(ctx, partial, true)
+	assert.Equal(t, ErrPartialRun, err, "Extra mocks can't be told apart when interfaces are left out.")
+
+	_, err = os.Stat(requester2)
+	assert.True(t, os.IsNotExist(err), "Check doesn't write anything.")
+}
+
+func TestCheckRequiresFiles(t *testing.T) {
+	_, err := Check(context.Background(), Options{Osp: &StdoutStreamProvider{}}, false)
+	assert.Equal(t, ErrNoOutputFiles, err)
+}
@@ -0,0 +1,204 @@
+package mockery
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Ways of telling apart the mocks of same-named interfaces of different
+// packages that would otherwise be written to the same file, or given the
+// same name in the same directory, see Options.Disambiguate.
+const (
+	// Prefix the names of the mock types and files with the package name,
+	// and with as many of its parent directories as needed
+	DisambiguatePrefix = "prefix"
+	// Write the mocks to a subdirectory named after the package, and as
+	// many of its parent directories as needed
+	DisambiguateSubdir = "subdir"
+)
+
+// plannedMock is where the mock of an interface would be written, and the
+// name of its type.
+type plannedMock struct {
+	iface *Interface
+	path  string
+	name  string
+}
+
+// collision is a file, or a mock type name within a directory, that the
+// mocks of several interfaces would get.
+type collision struct {
+	what   string
+	ifaces []*Interface
+}
+
+func (c collision) Error() string {
+	names := make([]string, len(c.ifaces))
+	for i, iface := range c.ifaces {
+		names[i] = iface.QualifiedName + "." + iface.Name
+	}
+	return fmt.Sprintf("the mocks of %s would all be %s", strings.Join(names, ", "), c.what)
+}
+
+// planMocks works out where the mocks of ifaces would go, with the visitor
+// visitorFor returns for each of them. Mocks that aren't written to files
+// are left out.
+func planMocks(ifaces []*Interface, visitorFor func(*Interface) *GeneratorVisitor, packageFiles bool) ([]plannedMock, error) {
+	var mocks []plannedMock
+	for _, iface := range ifaces {
+		visitor := visitorFor(iface)
+
+		var path string
+		var err error
+		if packageFiles {
+			path, err = visitor.packagePath(iface)
+		} else {
+			path, err = visitor.path(iface)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			continue
+		}
+
+		name, err := visitor.mockName(iface)
+		if err != nil {
+			return nil, err
+		}
+		mocks = append(mocks, plannedMock{iface: iface, path: path, name: name})
+	}
+	return mocks, nil
+}
+
+// findCollisions returns the files, and mock type names within a directory,
+// that several mocks would get, in the order of mocks. With packageFiles,
+// the mocks of the interfaces of a package are meant to share a file.
+func findCollisions(mocks []plannedMock, packageFiles bool) []collision {
+	var collisions []collision
+
+	var paths []string
+	byPath := make(map[string][]plannedMock)
+	var types []string
+	byType := make(map[string][]plannedMock)
+	for _, mock := range mocks {
+		if _, ok := byPath[mock.path]; !ok {
+			paths = append(paths, mock.path)
+		}
+		byPath[mock.path] = append(byPath[mock.path], mock)
+
+		key := filepath.Dir(mock.path) + "\x00" + mock.name
+		if _, ok := byType[key]; !ok {
+			types = append(types, key)
+		}
+		byType[key] = append(byType[key], mock)
+	}
+
+	for _, path := range paths {
+		group := byPath[path]
+		packages := make(map[string]bool)
+		for _, mock := range group {
+			packages[mock.iface.QualifiedName] = true
+		}
+		if len(group) > 1 && (!packageFiles || len(packages) > 1) {
+			collisions = append(collisions, collision{
+				what:   "written to " + path,
+				ifaces: plannedInterfaces(group),
+			})
+		}
+	}
+
+	for _, key := range types {
+		group := byType[key]
+		// Mocks of the same file were reported above, or are meant to share it.
+		samePath := true
+		for _, mock := range group {
+			samePath = samePath && mock.path == group[0].path
+		}
+		if len(group) > 1 && !samePath {
+			collisions = append(collisions, collision{
+				what:   fmt.Sprintf("named %s in %s", group[0].name, filepath.Dir(group[0].path)),
+				ifaces: plannedInterfaces(group),
+			})
+		}
+	}
+
+	return collisions
+}
+
+func plannedInterfaces(mocks []plannedMock) []*Interface {
+	ifaces := make([]*Interface, len(mocks))
+	for i, mock := range mocks {
+		ifaces[i] = mock.iface
+	}
+	return ifaces
+}
+
+func collisionErrors(collisions []collision) error {
+	errs := make(Errors, len(collisions))
+	for i, c := range collisions {
+		errs[i] = c
+	}
+	return errs
+}
+
+// distinguishingElems returns, for the package of each of ifaces, the
+// shortest trailing elements of its import path that no other package of
+// ifaces has. It fails when several of ifaces are of the same package.
+func distinguishingElems(c collision) (map[string][]string, error) {
+	var paths [][]string
+	seen := make(map[string]bool)
+	for _, iface := range c.ifaces {
+		if seen[iface.QualifiedName] {
+			return nil, c
+		}
+		seen[iface.QualifiedName] = true
+		paths = append(paths, strings.Split(iface.QualifiedName, "/"))
+	}
+
+	for n := 1; ; n++ {
+		elems := make(map[string][]string)
+		suffixes := make(map[string]bool)
+		done := true
+		for _, path := range paths {
+			suffix := path
+			if n < len(path) {
+				suffix = path[len(path)-n:]
+			}
+			key := strings.Join(suffix, "/")
+			if suffixes[key] {
+				done = false
+			}
+			suffixes[key] = true
+			elems[strings.Join(path, "/")] = suffix
+		}
+		if done {
+			return elems, nil
+		}
+	}
+}
+
+// disambiguate changes the visitor, and its FileOutputStreamProvider, to
+// tell its mocks apart with the given elements of their package's import
+// path.
+func (this *GeneratorVisitor) disambiguate(strategy string, elems []string) {
+	fop, ok := this.Osp.(*FileOutputStreamProvider)
+	if !ok {
+		return
+	}
+	osp := *fop
+	this.Osp = &osp
+
+	switch strategy {
+	case DisambiguatePrefix:
+		var prefix string
+		for _, elem := range elems {
+			prefix += firstUpper(invalidIdentifierChar.ReplaceAllString(elem, ""))
+		}
+		this.NamePrefix = prefix
+		osp.NamePrefix = prefix
+	case DisambiguateSubdir:
+		osp.BaseDir = filepath.Join(append([]string{osp.BaseDir}, elems...)...)
+	}
+}
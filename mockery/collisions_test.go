@@ -0,0 +1,92 @@
+package mockery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const collisionPkg = "github.com/namely/mockery/mockery/fixtures/testdata/collision"
+
+func TestGenerateCollisions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files, err := Generate(context.Background(), Options{
+		Dir:       getFixturePath("testdata", "collision"),
+		Recursive: true,
+		KeepGoing: true,
+		Osp:       &FileOutputStreamProvider{BaseDir: dir},
+	})
+	require.Error(t, err)
+	assert.Empty(t, files)
+
+	errs, ok := err.(Errors)
+	require.True(t, ok, "The error is an Errors.")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "the mocks of "+collisionPkg+"/a/store.Store, "+collisionPkg+"/b/store.Store, "+collisionPkg+"/c.Store would all be written to "+filepath.Join(dir, "Store.go"), errs[0].Error())
+
+	written, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, written, "Nothing is written.")
+}
+
+func TestGenerateCollisionsPrefix(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:          getFixturePath("testdata", "collision"),
+		Recursive:    true,
+		Disambiguate: DisambiguatePrefix,
+		Osp:          &FileOutputStreamProvider{BaseDir: "mocks"},
+		DryRun:       true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	for i, name := range []string{"AStoreStore", "BStoreStore", "CollisionCStore"} {
+		assert.Equal(t, filepath.Join("mocks", name+".go"), files[i].Path)
+		assert.Contains(t, string(files[i].Content), "type "+name+" struct {")
+	}
+}
+
+func TestGenerateCollisionsSubdir(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:          getFixturePath("testdata", "collision"),
+		Recursive:    true,
+		Disambiguate: DisambiguateSubdir,
+		PackageFiles: true,
+		Osp:          &FileOutputStreamProvider{BaseDir: "mocks"},
+		DryRun:       true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, filepath.Join("mocks", "a", "store", "store_mocks.go"), files[0].Path)
+	assert.Equal(t, filepath.Join("mocks", "b", "store", "store_mocks.go"), files[1].Path)
+	assert.Equal(t, filepath.Join("mocks", "collision", "c", "c_mocks.go"), files[2].Path)
+}
+
+func TestFindCollisionsOfTypeNames(t *testing.T) {
+	a := &Interface{Name: "Store", QualifiedName: "example.com/a"}
+	b := &Interface{Name: "Store", QualifiedName: "example.com/b"}
+	mocks := []plannedMock{
+		{iface: a, path: filepath.Join("mocks", "a_mocks.go"), name: "Store"},
+		{iface: b, path: filepath.Join("mocks", "b_mocks.go"), name: "Store"},
+	}
+
+	collisions := findCollisions(mocks, true)
+	require.Len(t, collisions, 1)
+	assert.Equal(t, "the mocks of example.com/a.Store, example.com/b.Store would all be named Store in mocks", collisions[0].Error())
+
+	elems, err := distinguishingElems(collisions[0])
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"example.com/a": {"a"}, "example.com/b": {"b"}}, elems)
+
+	_, err = distinguishingElems(collision{ifaces: []*Interface{a, {Name: "Other", QualifiedName: a.QualifiedName}}})
+	assert.Error(t, err, "Mocks of the same package can't be told apart by package.")
+}
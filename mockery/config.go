@@ -0,0 +1,381 @@
+package mockery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the configuration file looked for in the
+// searched directory and its parents.
+const ConfigFileName = ".mockery.yaml"
+
+// Settings are the options that can be set for every mock, the mocks of a
+// package or the mock of a single interface. Nil fields are left as they
+// are.
+type Settings struct {
+	// Directory to write mocks to, relative to the configuration file
+	Output        *string `yaml:"output"`
+	Outpkg        *string `yaml:"outpkg"`
+	InPkg         *bool   `yaml:"inpkg"`
+	TestOnly      *bool   `yaml:"testonly"`
+	Case          *string `yaml:"case"`
+	Note          *string `yaml:"note"`
+	ExpandAliases *bool   `yaml:"expandaliases"`
+	// Templates naming mock files and types, see NameData
+	FileName *string `yaml:"filename"`
+	MockName *string `yaml:"mockname"`
+}
+
+// Config is the content of a configuration file: default settings and
+// overrides by package import path and, within a package, by interface
+// name.
+type Config struct {
+	Settings `yaml:",inline"`
+	Packages map[string]PackageConfig `yaml:"packages"`
+}
+
+// PackageConfig overrides the default settings for the mocks of a package.
+type PackageConfig struct {
+	Settings   `yaml:",inline"`
+	Interfaces map[string]Settings `yaml:"interfaces"`
+}
+
+// FindConfig returns the path of the configuration file in dir or the
+// closest of its parents, or an empty string when there is none.
+func FindConfig(dir string) (string, error) {
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		if filepath.Dir(abs) == abs {
+			return "", nil
+		}
+		dir = filepath.Join(dir, "..")
+	}
+}
+
+// LoadConfig reads and validates a configuration file. Output directories
+// are made relative to the current directory rather than to the file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		return nil, yamlError(path, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, yamlError(path, err)
+	}
+	if err := config.validate(path, &root); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	config.Settings.resolve(dir)
+	for pkgPath, pkg := range config.Packages {
+		pkg.Settings.resolve(dir)
+		for name, iface := range pkg.Interfaces {
+			iface.resolve(dir)
+			pkg.Interfaces[name] = iface
+		}
+		config.Packages[pkgPath] = pkg
+	}
+
+	return &config, nil
+}
+
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// yamlError reports the errors of the YAML decoder as path:line: message.
+func yamlError(path string, err error) error {
+	msgs := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		msgs = typeErr.Errors
+	}
+
+	for i, msg := range msgs {
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			msgs[i] = fmt.Sprintf("%s:%s: %s", path, m[1], msg[len(m[0]):])
+		} else {
+			msgs[i] = fmt.Sprintf("%s: %s", path, msg)
+		}
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// validate checks the values the decoder can't. root is used to report the
+// position of invalid values.
+func (c *Config) validate(path string, root *yaml.Node) error {
+	fail := func(msg string, keys ...string) error {
+		return fmt.Errorf("%s:%d: %s", path, lineOf(root, keys...), msg)
+	}
+
+	if err := c.Settings.validate(fail); err != nil {
+		return err
+	}
+	// Keys are sorted so that the same problem is reported every time.
+	for _, pkgPath := range c.packagePaths() {
+		pkg := c.Packages[pkgPath]
+		if pkgPath == "" {
+			return fail("empty package path", "packages", pkgPath)
+		}
+		err := pkg.Settings.validate(func(msg string, keys ...string) error {
+			return fail(msg, append([]string{"packages", pkgPath}, keys...)...)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, name := range pkg.interfaceNames() {
+			iface := pkg.Interfaces[name]
+			if !token.IsIdentifier(name) {
+				return fail(fmt.Sprintf("invalid interface name %q", name), "packages", pkgPath, "interfaces", name)
+			}
+			err := iface.validate(func(msg string, keys ...string) error {
+				return fail(msg, append([]string{"packages", pkgPath, "interfaces", name}, keys...)...)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// packagePaths returns the keys of c.Packages, sorted.
+func (c *Config) packagePaths() []string {
+	paths := make([]string, 0, len(c.Packages))
+	for path := range c.Packages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// interfaceNames returns the keys of p.Interfaces, sorted.
+func (p PackageConfig) interfaceNames() []string {
+	names := make([]string, 0, len(p.Interfaces))
+	for name := range p.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputDirs returns the output directories set anywhere in c, sorted and
+// without duplicates.
+func (c *Config) outputDirs() []string {
+	seen := make(map[string]bool)
+	add := func(s Settings) {
+		if s.Output != nil {
+			seen[*s.Output] = true
+		}
+	}
+
+	add(c.Settings)
+	for _, pkg := range c.Packages {
+		add(pkg.Settings)
+		for _, iface := range pkg.Interfaces {
+			add(iface)
+		}
+	}
+
+	dirs := make([]string, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func (s Settings) validate(fail func(msg string, keys ...string) error) error {
+	if s.Output != nil && *s.Output == "" {
+		return fail("empty output directory", "output")
+	}
+	if s.Outpkg != nil && isNameTemplate(*s.Outpkg) {
+		if err := CheckNameTemplate(*s.Outpkg); err != nil {
+			return fail(err.Error(), "outpkg")
+		}
+	} else if s.Outpkg != nil && !token.IsIdentifier(*s.Outpkg) {
+		return fail(fmt.Sprintf("invalid package name %q", *s.Outpkg), "outpkg")
+	}
+	if s.Case != nil {
+		switch *s.Case {
+		case "camel", "snake", "underscore":
+		default:
+			return fail(fmt.Sprintf("invalid case %q, must be camel, snake or underscore", *s.Case), "case")
+		}
+	}
+	if s.FileName != nil {
+		if err := CheckNameTemplate(*s.FileName); err != nil {
+			return fail(err.Error(), "filename")
+		}
+	}
+	if s.MockName != nil {
+		if err := CheckNameTemplate(*s.MockName); err != nil {
+			return fail(err.Error(), "mockname")
+		}
+	}
+	return nil
+}
+
+// lineOf returns the line of the value at the given keys in a document, or
+// of the closest of its parents that exists.
+func lineOf(root *yaml.Node, keys ...string) int {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	for _, key := range keys {
+		var next *yaml.Node
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return node.Line
+}
+
+func (s *Settings) resolve(dir string) {
+	if s.Output != nil && !filepath.IsAbs(*s.Output) {
+		output := filepath.Join(dir, *s.Output)
+		s.Output = &output
+	}
+}
+
+// Merge returns s with the fields set in o replacing its own.
+func (s Settings) Merge(o Settings) Settings {
+	if o.Output != nil {
+		s.Output = o.Output
+	}
+	if o.Outpkg != nil {
+		s.Outpkg = o.Outpkg
+	}
+	if o.InPkg != nil {
+		s.InPkg = o.InPkg
+	}
+	if o.TestOnly != nil {
+		s.TestOnly = o.TestOnly
+	}
+	if o.Case != nil {
+		s.Case = o.Case
+	}
+	if o.Note != nil {
+		s.Note = o.Note
+	}
+	if o.ExpandAliases != nil {
+		s.ExpandAliases = o.ExpandAliases
+	}
+	if o.FileName != nil {
+		s.FileName = o.FileName
+	}
+	if o.MockName != nil {
+		s.MockName = o.MockName
+	}
+	return s
+}
+
+// For returns the settings of the mock of iface: the defaults, merged with
+// the overrides of its package and then with its own.
+func (c *Config) For(iface *Interface) Settings {
+	settings := c.Settings
+	if pkg, ok := c.Packages[iface.QualifiedName]; ok {
+		settings = settings.Merge(pkg.Settings)
+		if own, ok := pkg.Interfaces[iface.Name]; ok {
+			settings = settings.Merge(own)
+		}
+	}
+	return settings
+}
+
+// Lists tells whether the configuration names iface: it is one of the
+// interfaces listed for its package, or of a package listing none.
+func (c *Config) Lists(iface *Interface) bool {
+	pkg, ok := c.Packages[iface.QualifiedName]
+	if !ok {
+		return false
+	}
+	if len(pkg.Interfaces) == 0 {
+		return true
+	}
+	_, ok = pkg.Interfaces[iface.Name]
+	return ok
+}
+
+// apply returns a copy of v, and of its FileOutputStreamProvider, with the
+// settings in s.
+func (s Settings) apply(v *GeneratorVisitor) *GeneratorVisitor {
+	visitor := *v
+	if s.InPkg != nil {
+		visitor.InPackage = *s.InPkg
+	}
+	if s.Outpkg != nil {
+		visitor.PackageName = *s.Outpkg
+	}
+	if s.Note != nil {
+		visitor.Note = *s.Note
+	}
+	if s.ExpandAliases != nil {
+		visitor.ExpandAliases = *s.ExpandAliases
+	}
+	if s.MockName != nil {
+		visitor.MockName = *s.MockName
+	}
+
+	if fop, ok := v.Osp.(*FileOutputStreamProvider); ok {
+		osp := *fop
+		if s.Output != nil {
+			osp.BaseDir = *s.Output
+		}
+		if s.InPkg != nil {
+			osp.InPackage = *s.InPkg
+		}
+		if s.TestOnly != nil {
+			osp.TestOnly = *s.TestOnly
+		}
+		if s.Case != nil {
+			osp.Case = *s.Case
+		}
+		if s.FileName != nil {
+			osp.FileName = *s.FileName
+		}
+		visitor.Osp = &osp
+	}
+
+	return &visitor
+}
@@ -0,0 +1,162 @@
+package mockery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, ConfigFileName)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeConfig(t, dir, `
+output: mocks
+case: snake
+packages:
+  github.com/namely/mockery/mockery/fixtures:
+    outpkg: fixturemocks
+    interfaces:
+      Requester:
+        inpkg: true
+`)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	requester := &Interface{Name: "Requester", QualifiedName: "github.com/namely/mockery/mockery/fixtures"}
+	settings := config.For(requester)
+	assert.Equal(t, filepath.Join(dir, "mocks"), *settings.Output)
+	assert.Equal(t, "snake", *settings.Case)
+	assert.Equal(t, "fixturemocks", *settings.Outpkg)
+	assert.True(t, *settings.InPkg)
+	assert.Nil(t, settings.TestOnly)
+
+	other := config.For(&Interface{Name: "Requester", QualifiedName: "example.com/other"})
+	assert.Nil(t, other.Outpkg)
+	assert.Nil(t, other.InPkg)
+
+	note := "from the command line"
+	settings = settings.Merge(Settings{Note: &note})
+	assert.Equal(t, note, *settings.Note)
+	assert.Equal(t, "fixturemocks", *settings.Outpkg)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"output: mocks\noutptu: mocks\n", ".mockery.yaml:2: field outptu not found"},
+		{"output: [mocks\n", ".mockery.yaml:1: did not find expected ',' or ']'"},
+		{"packages:\n  example.com/store:\n    case: kebab\n", ".mockery.yaml:3: invalid case \"kebab\""},
+		{"packages:\n  example.com/store:\n    interfaces:\n      Store:\n        outpkg: my-mocks\n", ".mockery.yaml:5: invalid package name \"my-mocks\""},
+		{"packages:\n  example.com/store:\n    interfaces:\n      store.Store: {}\n", ".mockery.yaml:4: invalid interface name \"store.Store\""},
+		{"mockname: Mock{{.InterfaceName\n", ".mockery.yaml:1: invalid name template"},
+	}
+	for _, test := range tests {
+		_, err := LoadConfig(writeConfig(t, dir, test.content))
+		if assert.Error(t, err, test.content) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestLoadConfigErrorOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeConfig(t, dir, `
+packages:
+  example.com/b:
+    case: kebab
+  example.com/a:
+    interfaces:
+      Store:
+        case: kebab
+      Queue:
+        case: kebab
+`)
+	for i := 0; i < 10; i++ {
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ".mockery.yaml:10: invalid case", "The first problem in sorted order is reported.")
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	nested := filepath.Join(dir, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+
+	path, err := FindConfig(nested)
+	require.NoError(t, err)
+	assert.Equal(t, "", path)
+
+	writeConfig(t, dir, "")
+	path, err = FindConfig(nested)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, ConfigFileName), path)
+}
+
+func TestGenerateWithConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := LoadConfig(writeConfig(t, dir, `
+packages:
+  github.com/namely/mockery/mockery/fixtures:
+    output: fixturemocks
+    interfaces:
+      Requester2:
+        outpkg: requester2
+`))
+	require.NoError(t, err)
+
+	outpkg := "cli"
+	files, err := Generate(context.Background(), Options{
+		Dir:    fixturePath,
+		Filter: regexp.MustCompile("^Requester2?$"),
+		Osp:    &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+		DryRun: true,
+		Config: config,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, filepath.Join(dir, "fixturemocks", "Requester.go"), files[0].Path)
+	assert.Contains(t, string(files[0].Content), "\npackage mocks\n")
+	assert.Contains(t, string(files[1].Content), "\npackage requester2\n")
+
+	files, err = Generate(context.Background(), Options{
+		Dir:       fixturePath,
+		Filter:    regexp.MustCompile("^Requester2$"),
+		DryRun:    true,
+		Config:    config,
+		Overrides: Settings{Outpkg: &outpkg},
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Contains(t, string(files[0].Content), "\npackage cli\n", "Overrides take precedence.")
+}
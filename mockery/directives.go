@@ -0,0 +1,127 @@
+package mockery
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// directivePrefix starts the comments controlling the generation of the mock
+// of the interface they document.
+const directivePrefix = "//mockery:"
+
+// Directives are the //mockery: comments documenting an interface or the
+// declaration it belongs to. They take precedence over the command line and
+// the configuration file.
+type Directives struct {
+	// //mockery:skip, no mock is generated
+	Skip bool
+	// //mockery:name=FakeStore, the name of the mock type and file
+	Name string
+	// //mockery:output=internal/testutil, the directory to write the mock
+	// to, relative to the file declaring the interface
+	Output string
+	// //mockery:inpkg, the mock goes inside the interface's package
+	InPackage bool
+	// //mockery:lenient, results missing from an expectation are returned
+	// as zero values rather than making the mock panic
+	Lenient bool
+}
+
+// directiveError is a malformed directive, reported once positions can be
+// resolved.
+type directiveError struct {
+	pos token.Pos
+	msg string
+}
+
+// parseDirectives reads the directives of the given comment groups, any of
+// which may be nil. Later groups take precedence.
+func parseDirectives(groups ...*ast.CommentGroup) (Directives, []directiveError) {
+	var d Directives
+	var errs []directiveError
+
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, c := range group.List {
+			if !strings.HasPrefix(c.Text, directivePrefix) {
+				continue
+			}
+
+			key, value := strings.TrimSpace(c.Text[len(directivePrefix):]), ""
+			hasValue := false
+			if i := strings.Index(key, "="); i >= 0 {
+				key, value, hasValue = key[:i], key[i+1:], true
+			}
+
+			fail := func(format string, args ...interface{}) {
+				errs = append(errs, directiveError{pos: c.Pos(), msg: fmt.Sprintf(format, args...)})
+			}
+
+			switch key {
+			case "skip", "inpkg", "lenient":
+				if hasValue {
+					fail("directive %s%s takes no value", directivePrefix, key)
+					continue
+				}
+				switch key {
+				case "skip":
+					d.Skip = true
+				case "inpkg":
+					d.InPackage = true
+				case "lenient":
+					d.Lenient = true
+				}
+			case "name":
+				if !token.IsIdentifier(value) {
+					fail("invalid mock name %q in %sname", value, directivePrefix)
+					continue
+				}
+				d.Name = value
+			case "output":
+				if value == "" {
+					fail("empty directory in %soutput", directivePrefix)
+					continue
+				}
+				d.Output = value
+			default:
+				fail("unknown directive %s%s", directivePrefix, key)
+			}
+		}
+	}
+
+	return d, errs
+}
+
+// settings returns the directives of iface that override its settings.
+func (d Directives) settings(iface *Interface) Settings {
+	var s Settings
+	if d.Output != "" {
+		output := d.Output
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(filepath.Dir(iface.FileName), output)
+		}
+		s.Output = &output
+	}
+	if d.InPackage {
+		inPackage := true
+		s.InPkg = &inPackage
+	}
+	return s
+}
+
+// interfaceSettings returns the settings iface imposes on its mock: those of
+// its directives and, for interfaces declared in a _test.go file, which
+// can't be imported, a mock in a _test.go file of the same package.
+func interfaceSettings(iface *Interface) Settings {
+	s := iface.Directives.settings(iface)
+	if strings.HasSuffix(iface.FileName, "_test.go") {
+		inPackage, testOnly := true, true
+		s.InPkg, s.TestOnly = &inPackage, &testOnly
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package mockery
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDirectives(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "store.go", `package store
+
+// Store is documented.
+//
+//mockery:name=FakeStore
+//mockery:output=internal/testutil
+//mockery:inpkg
+//go:generate echo ignored
+//mockery:lenient
+type Store interface{}
+
+//mockery:skip=true
+//mockery:name=fake-store
+//mockery:unknown
+type Broken interface{}
+`, parser.ParseComments)
+	require.NoError(t, err)
+
+	directives, errs := parseDirectives(file.Decls[0].(*ast.GenDecl).Doc)
+	assert.Empty(t, errs)
+	assert.Equal(t, Directives{
+		Name:      "FakeStore",
+		Output:    "internal/testutil",
+		InPackage: true,
+		Lenient:   true,
+	}, directives)
+
+	directives, errs = parseDirectives(file.Decls[1].(*ast.GenDecl).Doc)
+	assert.Equal(t, Directives{}, directives)
+	require.Len(t, errs, 3)
+	assert.Equal(t, "directive //mockery:skip takes no value", errs[0].msg)
+	assert.Equal(t, `invalid mock name "fake-store" in //mockery:name`, errs[1].msg)
+	assert.Equal(t, "unknown directive //mockery:unknown", errs[2].msg)
+	assert.Equal(t, 14, fset.Position(errs[2].pos).Line)
+}
+
+func TestGenerateWithDirectives(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:    fixturePath,
+		Filter: regexp.MustCompile("^Directive"),
+		Osp:    &FileOutputStreamProvider{BaseDir: "mocks"},
+		DryRun: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 2, "DirectiveSkipped is skipped.")
+
+	assert.Equal(t, "DirectiveInPackage", files[0].Interface.Name)
+	assert.True(t, files[0].Interface.Directives.InPackage, "Directives of the declaration apply to its specs.")
+	assert.Equal(t, filepath.Join(fixturePath, "mock_DirectiveInPackage.go"), files[0].Path)
+	assert.Contains(t, string(files[0].Content), "\npackage test\n")
+
+	assert.Equal(t, "DirectiveStore", files[1].Interface.Name)
+	assert.Equal(t, filepath.Join("mocks", "FakeStore.go"), files[1].Path)
+	assert.Contains(t, string(files[1].Content), "\ntype FakeStore struct {\n")
+	assert.Contains(t, string(files[1].Content), "\tif len(ret) > 0 {\n")
+}
@@ -0,0 +1,13 @@
+package test
+
+import (
+	"github.com/namely/mockery/mockery/fixtures/http"
+)
+
+type ID = string
+
+type IDs = []ID
+
+type RequesterAlias interface {
+	Get(id ID, ids IDs) (http.MyStructAlias, error)
+}
@@ -0,0 +1,23 @@
+package test
+
+// DirectiveSkipped isn't mocked.
+//
+//mockery:skip
+type DirectiveSkipped interface {
+	Get() string
+}
+
+// DirectiveStore is mocked as FakeStore.
+//
+//mockery:name=FakeStore
+//mockery:lenient
+type DirectiveStore interface {
+	Get(key string) (string, error)
+}
+
+//mockery:inpkg
+type (
+	DirectiveInPackage interface {
+		Get() string
+	}
+)
@@ -1,3 +1,5 @@
 package http
 
 type MyStruct struct{}
+
+type MyStructAlias = MyStruct
@@ -0,0 +1,11 @@
+package test
+
+import (
+	mock "github.com/namely/mockery/mockery/fixtures/http"
+)
+
+// ExampleAliasConflict imports a package with an alias that conflicts with
+// the imports of the mock.
+type ExampleAliasConflict interface {
+	Get() mock.MyStruct
+}
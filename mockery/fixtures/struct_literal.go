@@ -0,0 +1,19 @@
+package test
+
+import (
+	"net/http"
+)
+
+type Options struct {
+	Verbose bool
+}
+
+type StructLiteral interface {
+	Configure(opts struct {
+		Name    string `json:"name"`
+		Timeout int    `json:"timeout,omitempty"`
+		*Options
+		http.Header `json:"-"`
+	}) error
+	Empty() struct{}
+}
@@ -0,0 +1,6 @@
+package store
+
+// Store has the same name as the interfaces of the other collision packages.
+type Store interface {
+	Get(key string) (string, error)
+}
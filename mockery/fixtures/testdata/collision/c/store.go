@@ -0,0 +1,6 @@
+package c
+
+// Store has the same name as the interfaces of the other collision packages.
+type Store interface {
+	Delete(key string) error
+}
@@ -0,0 +1,8 @@
+package testpkg_test
+
+import "github.com/namely/mockery/mockery/fixtures/testpkg"
+
+// Client is declared in the external test package.
+type Client interface {
+	Service() testpkg.Service
+}
@@ -0,0 +1,8 @@
+package testpkg
+
+import "time"
+
+// Clock is a seam only the tests of the package use.
+type Clock interface {
+	Now() time.Time
+}
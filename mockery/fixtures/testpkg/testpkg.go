@@ -0,0 +1,5 @@
+package testpkg
+
+type Service interface {
+	Do() error
+}
@@ -0,0 +1,10 @@
+//go:build unmockable
+// +build unmockable
+
+package test
+
+// StructLiteralUnexported can only be mocked in package, so it is kept out of
+// the default build to let -all succeed on the fixtures.
+type StructLiteralUnexported interface {
+	Get() struct{ name string }
+}
@@ -0,0 +1,513 @@
+package mockery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Logger receives the progress and diagnostic messages of a run. *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+func logf(logger Logger, format string, args ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, args...)
+	}
+}
+
+// Options configures Generate. The zero value generates every interface found
+// in the current directory without writing anything.
+type Options struct {
+	// Directory to search for interfaces, "." when empty
+	Dir string
+	// Directories to search instead of Dir when not empty, see Walker.Dirs
+	Dirs      []string
+	Recursive bool
+	// Import path of a package to mock interfaces from instead of searching
+	// Dir, see Walker
+	SrcPkg string
+	// Interfaces whose name match are generated, all of them when nil
+	Filter *regexp.Regexp
+	// Interfaces to generate by name, see Walker.Names
+	Names []InterfaceName
+	// Used instead of Names when its qualifier names no package, see
+	// Walker.NamesFallback
+	NamesFallback *regexp.Regexp
+	// Stop after the first interface matching Filter
+	LimitOne  bool
+	BuildTags []string
+	// Carry on past errors and return all of them as Errors
+	KeepGoing bool
+	// Directories and interfaces to leave out or, when Include isn't empty,
+	// to limit the run to, see Walker. The output directory is always left
+	// out.
+	Exclude []Pattern
+	Include []Pattern
+	// Number of mocks generated at once, GOMAXPROCS when zero
+	Jobs int
+	// Also generate mocks for the interfaces declared in _test.go files
+	IncludeTests bool
+
+	InPackage bool
+	Note      string
+	// The name of the output package, if InPackage is false (defaults to
+	// "mocks"), or a template, see GeneratorVisitor.PackageName
+	PackageName   string
+	ExpandAliases bool
+	// Template naming the mock types, see GeneratorVisitor.MockName
+	MockName string
+	// Directory caching generated mocks, see GeneratorVisitor.CacheDir
+	CacheDir string
+	// Write the mocks of each source package into a single file, see
+	// FileOutputStreamProvider.PackagePath, rather than one per interface
+	PackageFiles bool
+	// How the mocks of same-named interfaces of different packages that
+	// would be written to the same file, or get the same name in the same
+	// directory, are told apart: DisambiguatePrefix or DisambiguateSubdir.
+	// When empty, such collisions fail the run before anything is written.
+	Disambiguate string
+
+	// Settings from a configuration file, applied per interface on top of
+	// the options above
+	Config *Config
+	// Applied on top of Config, such as the flags given on the command line
+	Overrides Settings
+
+	// Where mocks are written, they are only returned when nil
+	Osp OutputStreamProvider
+	// Work out the paths Osp would write mocks to without writing them
+	DryRun bool
+	// Receives progress messages, nothing is logged when nil
+	Logger Logger
+	// When not nil, receives an entry for every interface visited, including
+	// those that failed, and the error of the run
+	Report *Report
+}
+
+// GeneratedFile is a mock produced by Generate.
+type GeneratedFile struct {
+	Interface *Interface
+	// Every interface mocked in the file, in order, Interface being the
+	// first. It only has several with PackageFiles.
+	Interfaces []*Interface
+	// The file the mock was (or, with DryRun, would be) written to, empty
+	// unless Osp writes to files
+	Path    string
+	Content []byte
+	// The mock on disk already had the same fingerprint, so it was neither
+	// generated nor written again
+	Unchanged bool
+	// The mock wasn't generated, as it was Unchanged or CacheDir held a mock
+	// with its fingerprint
+	Cached bool
+	// The file didn't exist or had a different content before it was (or,
+	// with DryRun, would be) written
+	Changed bool
+}
+
+// Generate runs the same pipeline as the mockery command: it walks
+// opts.Dir, generates a mock for each matching interface and writes it
+// through opts.Osp. Errors are returned rather than printed, and ctx can be
+// used to cancel the run.
+func Generate(ctx context.Context, opts Options) ([]GeneratedFile, error) {
+	walker, visitor, err := newRun(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PackageFiles {
+		err = visitor.visitPackages(ctx, walker)
+	} else {
+		_, err = walker.WalkContext(ctx, visitor)
+	}
+	if err != nil && opts.Report != nil {
+		opts.Report.Error = err.Error()
+	}
+	return visitor.files, err
+}
+
+// newRun returns the walker and the visitor of a run with opts, defaults
+// applied.
+func newRun(opts Options) (*Walker, *collectingVisitor, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Filter == nil {
+		opts.Filter = regexp.MustCompile(".*")
+	}
+	if opts.PackageName == "" {
+		opts.PackageName = "mocks"
+	}
+	if opts.SrcPkg != "" {
+		// The package may be in GOROOT or the module cache, and isn't
+		// under Dir.
+		fop, _ := opts.Osp.(*FileOutputStreamProvider)
+		if opts.InPackage || opts.IncludeTests || (fop != nil && (fop.InPackage || fop.KeepTree)) {
+			return nil, nil, errors.New("SrcPkg can't be used with InPackage, IncludeTests or KeepTree")
+		}
+	}
+	switch opts.Disambiguate {
+	case "", DisambiguatePrefix, DisambiguateSubdir:
+	default:
+		return nil, nil, fmt.Errorf("invalid disambiguation %q, must be %s or %s", opts.Disambiguate, DisambiguatePrefix, DisambiguateSubdir)
+	}
+
+	walker := &Walker{
+		BaseDir:       opts.Dir,
+		Dirs:          opts.Dirs,
+		SrcPkg:        opts.SrcPkg,
+		Recursive:     opts.Recursive,
+		Filter:        opts.Filter,
+		Names:         opts.Names,
+		NamesFallback: opts.NamesFallback,
+		LimitOne:      opts.LimitOne,
+		BuildTags:     opts.BuildTags,
+		KeepGoing:     opts.KeepGoing,
+		Exclude:       append(outputExclusions(opts), opts.Exclude...),
+		Include:       opts.Include,
+		Jobs:          opts.Jobs,
+		IncludeTests:  opts.IncludeTests,
+	}
+
+	visitor := &collectingVisitor{
+		GeneratorVisitor: &GeneratorVisitor{
+			InPackage:     opts.InPackage,
+			Note:          opts.Note,
+			Osp:           opts.Osp,
+			PackageName:   opts.PackageName,
+			ExpandAliases: opts.ExpandAliases,
+			MockName:      opts.MockName,
+			CacheDir:      opts.CacheDir,
+			Logger:        opts.Logger,
+		},
+		config:       opts.Config,
+		overrides:    opts.Overrides,
+		dryRun:       opts.DryRun,
+		packageFiles: opts.PackageFiles,
+		disambiguate: opts.Disambiguate,
+		report:       opts.Report,
+	}
+	return walker, visitor, nil
+}
+
+// outputExclusions returns patterns matching the directories mocks are
+// written to, when they are under one of the searched directories, so that
+// mocks aren't mocked in turn.
+func outputExclusions(opts Options) []Pattern {
+	var patterns []Pattern
+	for _, output := range opts.outputDirs() {
+		output, err := filepath.Abs(output)
+		if err != nil {
+			continue
+		}
+		for _, dir := range opts.dirs() {
+			dir, err := filepath.Abs(dir)
+			if err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(dir, output)
+			if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			patterns = append(patterns, exactPattern(filepath.ToSlash(rel)))
+		}
+	}
+	return patterns
+}
+
+// outputDirs returns the directories mocks are written to outside of their
+// packages: the BaseDir of opts.Osp and those set in opts.Config, unless
+// opts.Overrides sets the output of every mock.
+func (opts Options) outputDirs() []string {
+	fop, ok := opts.Osp.(*FileOutputStreamProvider)
+	if !ok || (opts.Overrides.InPkg != nil && *opts.Overrides.InPkg) {
+		return nil
+	}
+	if opts.Overrides.Output != nil {
+		return []string{*opts.Overrides.Output}
+	}
+
+	var dirs []string
+	if !fop.InPackage {
+		dirs = append(dirs, fop.BaseDir)
+	}
+	if opts.Config != nil {
+		dirs = append(dirs, opts.Config.outputDirs()...)
+	}
+	return dirs
+}
+
+// dirs returns the directories searched for interfaces.
+func (opts Options) dirs() []string {
+	if len(opts.Dirs) > 0 {
+		return opts.Dirs
+	}
+	if opts.Dir == "" {
+		return []string{"."}
+	}
+	return []string{opts.Dir}
+}
+
+// partial tells whether opts leave some of the interfaces of the searched
+// directories out by package, name or pattern. Filter is left to the
+// caller.
+func (opts Options) partial() bool {
+	return opts.SrcPkg != "" || len(opts.Names) > 0 || len(opts.Exclude) > 0 || len(opts.Include) > 0
+}
+
+// collectingVisitor generates mocks like GeneratorVisitor and keeps them.
+type collectingVisitor struct {
+	*GeneratorVisitor
+	config       *Config
+	overrides    Settings
+	dryRun       bool
+	packageFiles bool
+	disambiguate string
+	// The import path elements telling apart colliding mocks, by
+	// disambiguationKey
+	disambiguations map[string][]string
+	files           []GeneratedFile
+	report          *Report
+	// The mocks generated in the previous rounds of a watch, not generated
+	// again while their fingerprint stays the same
+	watched *watchState
+}
+
+func (this *collectingVisitor) VisitWalk(iface *Interface) error {
+	done, err := this.PrepareWalk(iface)
+	done()
+	return err
+}
+
+// PrepareWalk generates the mock of iface and, unless it's a dry run, writes
+// it. The mock is only added to the files, in the order of the walk, by
+// done.
+func (this *collectingVisitor) PrepareWalk(iface *Interface) (done func(), err error) {
+	visitor, logs := this.visitorFor(iface).buffered()
+	var fingerprint string
+	if this.watched != nil {
+		fingerprint = visitor.fingerprint(iface)
+		if path, err := visitor.path(iface); err == nil && this.watched.unchanged(path, fingerprint) {
+			return func() {}, nil
+		}
+	}
+
+	file, err := this.visit(visitor, iface)
+	return func() {
+		logs.flush()
+		this.report.add(visitor, file, err)
+		if err == nil {
+			this.files = append(this.files, file)
+			this.watched.record(file.Path, fingerprint)
+		}
+	}, err
+}
+
+// visit returns the mock of iface, as far as it got on error.
+func (this *collectingVisitor) visit(visitor *GeneratorVisitor, iface *Interface) (file GeneratedFile, err error) {
+	file = GeneratedFile{Interface: iface, Interfaces: []*Interface{iface}}
+	if file.Path, err = visitor.path(iface); err != nil {
+		return file, err
+	}
+	if file.Content, file.Unchanged, file.Cached, err = visitor.render(iface, file.Path); err != nil {
+		return file, err
+	}
+
+	file.Changed = !file.Unchanged && changes(file.Path, file.Content)
+	if visitor.Osp != nil && !this.dryRun && !file.Unchanged {
+		if err := visitor.write(iface, file.Path, file.Content); err != nil {
+			return file, err
+		}
+	}
+	return file, nil
+}
+
+// visitorFor returns the visitor generating the mock of iface, with the
+// settings of the configuration, the overrides and the directives of iface
+// applied.
+func (this *collectingVisitor) visitorFor(iface *Interface) *GeneratorVisitor {
+	var settings Settings
+	if this.config != nil {
+		settings = this.config.For(iface)
+	}
+	settings = settings.Merge(this.overrides).Merge(interfaceSettings(iface))
+	visitor := settings.apply(this.GeneratorVisitor)
+	if elems, ok := this.disambiguations[this.disambiguationKey(iface)]; ok {
+		visitor.disambiguate(this.disambiguate, elems)
+	}
+	return visitor
+}
+
+// PlanWalk checks that no two mocks would be written to the same file, or
+// get the same name in the same directory, and tells them apart with the
+// Disambiguate strategy when there is one.
+func (this *collectingVisitor) PlanWalk(ifaces []*Interface) error {
+	// Watches plan each round again, from scratch.
+	this.disambiguations = nil
+	mocks, err := planMocks(ifaces, this.visitorFor, this.packageFiles)
+	if err != nil {
+		return err
+	}
+	collisions := findCollisions(mocks, this.packageFiles)
+	if len(collisions) == 0 {
+		return nil
+	} else if this.disambiguate == "" {
+		return collisionErrors(collisions)
+	}
+
+	this.disambiguations = make(map[string][]string)
+	for _, c := range collisions {
+		elems, err := distinguishingElems(c)
+		if err != nil {
+			return err
+		}
+		for _, iface := range c.ifaces {
+			key := this.disambiguationKey(iface)
+			if pkgElems := elems[iface.QualifiedName]; len(pkgElems) > len(this.disambiguations[key]) {
+				this.disambiguations[key] = pkgElems
+			}
+		}
+	}
+
+	mocks, err = planMocks(ifaces, this.visitorFor, this.packageFiles)
+	if err != nil {
+		return err
+	}
+	if collisions := findCollisions(mocks, this.packageFiles); len(collisions) > 0 {
+		return collisionErrors(collisions)
+	}
+	return nil
+}
+
+// disambiguationKey tells which mocks are told apart together: those of a
+// package when they share a file, or else each of them.
+func (this *collectingVisitor) disambiguationKey(iface *Interface) string {
+	if this.packageFiles {
+		return iface.QualifiedName
+	}
+	return iface.QualifiedName + "." + iface.Name
+}
+
+// interfaceList is a WalkerVisitor collecting the interfaces it visits.
+type interfaceList []*Interface
+
+func (l *interfaceList) VisitWalk(iface *Interface) error {
+	*l = append(*l, iface)
+	return nil
+}
+
+// packageFile is a file holding the mocks of several interfaces of a package.
+type packageFile struct {
+	visitor *GeneratorVisitor
+	path    string
+	ifaces  []*Interface
+}
+
+// visitPackages walks for the interfaces first, and then generates a single
+// file for those of each source package that have the same output file and
+// settings, in the order they were found.
+func (this *collectingVisitor) visitPackages(ctx context.Context, walker *Walker) error {
+	var ifaces interfaceList
+	_, err := walker.WalkContext(ctx, &ifaces)
+	errs, keptGoing := err.(Errors)
+	if err != nil && !keptGoing {
+		return err
+	}
+
+	if err := this.PlanWalk(ifaces); err != nil {
+		return err
+	}
+
+	pkgFiles, err := this.groupPackageFiles(ifaces)
+	if err != nil {
+		return err
+	}
+
+	for _, pkgFile := range pkgFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := this.visitPackage(pkgFile); err != nil {
+			err = fmt.Errorf("error walking %s: %w", pkgFile.ifaces[0].Pkg.Name(), err)
+			if err := walker.fail(&errs, err); err != nil {
+				return err
+			}
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// groupPackageFiles groups ifaces by the file their mocks are written to.
+// Mocks of different packages, or with different settings, can't share a
+// file.
+func (this *collectingVisitor) groupPackageFiles(ifaces []*Interface) ([]*packageFile, error) {
+	var pkgFiles []*packageFile
+	byKey := make(map[string]*packageFile)
+	for _, iface := range ifaces {
+		visitor := this.visitorFor(iface)
+		path, err := visitor.packagePath(iface)
+		if err != nil {
+			return nil, err
+		}
+
+		key := path
+		if key == "" {
+			key = iface.QualifiedName + "." + iface.Pkg.Name()
+		}
+		pkgFile, ok := byKey[key]
+		if !ok {
+			pkgFile = &packageFile{visitor: visitor, path: path}
+			byKey[key] = pkgFile
+			pkgFiles = append(pkgFiles, pkgFile)
+		} else if first := pkgFile.ifaces[0]; first.QualifiedName != iface.QualifiedName {
+			return nil, fmt.Errorf("mocks of %s.%s and %s.%s would both be written to %s", first.QualifiedName, first.Name, iface.QualifiedName, iface.Name, path)
+		} else if !visitor.sameFile(pkgFile.visitor) {
+			return nil, fmt.Errorf("mocks of %s and %s are written to %s with different settings", first.Name, iface.Name, path)
+		}
+		pkgFile.ifaces = append(pkgFile.ifaces, iface)
+	}
+	return pkgFiles, nil
+}
+
+func (this *collectingVisitor) visitPackage(pkgFile *packageFile) error {
+	var fingerprint string
+	if this.watched != nil {
+		fingerprint = pkgFile.visitor.packageFingerprint(pkgFile.ifaces)
+		if this.watched.unchanged(pkgFile.path, fingerprint) {
+			return nil
+		}
+	}
+
+	file, err := this.renderPackage(pkgFile)
+	this.report.add(pkgFile.visitor, file, err)
+	if err != nil {
+		return err
+	}
+	this.files = append(this.files, file)
+	this.watched.record(file.Path, fingerprint)
+	return nil
+}
+
+// renderPackage returns the file of pkgFile, as far as it got on error, and
+// writes it unless it's a dry run.
+func (this *collectingVisitor) renderPackage(pkgFile *packageFile) (file GeneratedFile, err error) {
+	visitor := pkgFile.visitor
+	file = GeneratedFile{Interface: pkgFile.ifaces[0], Interfaces: pkgFile.ifaces, Path: pkgFile.path}
+	if file.Content, file.Unchanged, file.Cached, err = visitor.renderPackage(pkgFile.ifaces, pkgFile.path); err != nil {
+		return file, err
+	}
+
+	file.Changed = !file.Unchanged && changes(file.Path, file.Content)
+	if visitor.Osp != nil && !this.dryRun && !file.Unchanged {
+		if err := visitor.writePackage(pkgFile.ifaces, pkgFile.path, file.Content); err != nil {
+			return file, err
+		}
+	}
+	return file, nil
+}
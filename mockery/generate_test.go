@@ -0,0 +1,379 @@
+package mockery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func TestGenerateWithoutOutput(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:      fixturePath,
+		Filter:   regexp.MustCompile("^Requester2$"),
+		LimitOne: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	assert.Equal(t, "Requester2", files[0].Interface.Name)
+	assert.Equal(t, "", files[0].Path)
+	assert.Contains(t, string(files[0].Content), "package mocks")
+	assert.Contains(t, string(files[0].Content), "type Requester2 struct {")
+}
+
+func TestGenerateWritesFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logger := &recordingLogger{}
+	files, err := Generate(context.Background(), Options{
+		Dir:      fixturePath,
+		Filter:   regexp.MustCompile("^Requester2$"),
+		LimitOne: true,
+		Osp:      &FileOutputStreamProvider{BaseDir: dir},
+		Logger:   logger,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	path := filepath.Join(dir, "Requester2.go")
+	assert.Equal(t, path, files[0].Path)
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, files[0].Content, written)
+	assert.Equal(t, []string{"Generating mock for: Requester2 in file: " + path}, logger.lines)
+}
+
+func TestGenerateReturnsAllErrors(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:       fixturePath,
+		Filter:    regexp.MustCompile("^(StructLiteralUnexported|Requester2)$"),
+		BuildTags: []string{"unmockable"},
+		KeepGoing: true,
+	})
+	require.Error(t, err)
+
+	errs, ok := err.(Errors)
+	require.True(t, ok, "The error is an Errors.")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "StructLiteralUnexported")
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "Requester2", files[0].Interface.Name)
+}
+
+func TestGenerateStopsAtFirstError(t *testing.T) {
+	_, err := Generate(context.Background(), Options{
+		Dir:       fixturePath,
+		Filter:    regexp.MustCompile("^StructLiteralUnexported$"),
+		BuildTags: []string{"unmockable"},
+	})
+	require.Error(t, err)
+
+	_, ok := err.(Errors)
+	assert.False(t, ok, "The first error is returned as is.")
+	var genErr *GenerateError
+	assert.True(t, errors.As(err, &genErr), "The error wraps a *GenerateError.")
+}
+
+func TestGenerateConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// Its mock would be written next to it, among the fixtures.
+	inPackage, err := ParsePattern("fixtures.DirectiveInPackage")
+	require.NoError(t, err)
+
+	generate := func(jobs int) ([]GeneratedFile, []string, error) {
+		logger := &recordingLogger{}
+		files, err := Generate(context.Background(), Options{
+			Dir:       fixturePath,
+			BuildTags: []string{"unmockable"},
+			KeepGoing: true,
+			Exclude:   []Pattern{inPackage},
+			Jobs:      jobs,
+			Osp:       &FileOutputStreamProvider{BaseDir: dir},
+			Logger:    logger,
+		})
+		return files, logger.lines, err
+	}
+
+	sequentialFiles, sequentialLogs, sequentialErr := generate(1)
+	concurrentFiles, concurrentLogs, concurrentErr := generate(8)
+
+	require.Error(t, concurrentErr)
+	assert.Equal(t, sequentialErr.Error(), concurrentErr.Error())
+	assert.Equal(t, sequentialLogs, concurrentLogs)
+	require.Equal(t, len(sequentialFiles), len(concurrentFiles))
+	assert.True(t, len(concurrentFiles) > 10)
+	for i := range sequentialFiles {
+		assert.Equal(t, sequentialFiles[i].Path, concurrentFiles[i].Path)
+		assert.Equal(t, sequentialFiles[i].Content, concurrentFiles[i].Content)
+	}
+}
+
+func TestGenerateCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files, err := Generate(ctx, Options{Dir: fixturePath})
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, files)
+}
+
+func TestOutputExclusions(t *testing.T) {
+	exclusions := outputExclusions(Options{Dir: ".", Osp: &FileOutputStreamProvider{BaseDir: "./mocks/store"}})
+	require.Len(t, exclusions, 1)
+	assert.True(t, matchDir(exclusions, "mocks/store"))
+	assert.True(t, matchDir(exclusions, "mocks/store/nested"))
+	assert.False(t, matchDir(exclusions, "mocks"))
+
+	assert.Empty(t, outputExclusions(Options{Dir: "./svc", Osp: &FileOutputStreamProvider{BaseDir: "./mocks"}}))
+	assert.Empty(t, outputExclusions(Options{Dir: ".", Osp: &FileOutputStreamProvider{BaseDir: "./mocks", InPackage: true}}))
+
+	storeMocks := "./store/mocks"
+	config := &Config{Packages: map[string]PackageConfig{"example.com/store": {Settings: Settings{Output: &storeMocks}}}}
+	exclusions = outputExclusions(Options{Dir: ".", Osp: &FileOutputStreamProvider{BaseDir: "./mocks"}, Config: config})
+	assert.True(t, matchDir(exclusions, "mocks"))
+	assert.True(t, matchDir(exclusions, "store/mocks"), "Output directories of the configuration are excluded.")
+	assert.False(t, matchDir(exclusions, "store"))
+
+	output := "./mocks"
+	exclusions = outputExclusions(Options{Dir: ".", Osp: &FileOutputStreamProvider{BaseDir: "./mocks"}, Config: config, Overrides: Settings{Output: &output}})
+	assert.False(t, matchDir(exclusions, "store/mocks"), "The output given on the command line replaces those of the configuration.")
+}
+
+func TestGenerateFromImportPath(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		SrcPkg: "io",
+		Filter: regexp.MustCompile("^ReadWriteCloser$"),
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "io", files[0].Interface.QualifiedName)
+	assert.Contains(t, string(files[0].Content), "func (_m *ReadWriteCloser) Close() error {")
+
+	files, err = Generate(context.Background(), Options{
+		SrcPkg: "github.com/stretchr/testify/mock",
+		Filter: regexp.MustCompile("^TestingT$"),
+	})
+	require.NoError(t, err, "Packages are resolved through the module's requirements.")
+	require.Len(t, files, 1)
+	assert.Contains(t, string(files[0].Content), "type TestingT struct {")
+
+	_, err = Generate(context.Background(), Options{SrcPkg: "example.com/does/not/exist"})
+	assert.Error(t, err)
+
+	_, err = Generate(context.Background(), Options{SrcPkg: "io", InPackage: true})
+	assert.Error(t, err, "Mocks aren't written next to packages outside of the module.")
+	_, err = Generate(context.Background(), Options{SrcPkg: "io", Osp: &FileOutputStreamProvider{KeepTree: true}})
+	assert.Error(t, err)
+}
+
+func TestGenerateIncludeTests(t *testing.T) {
+	dir := getFixturePath("testpkg")
+	opts := Options{
+		Dir:    dir,
+		Osp:    &FileOutputStreamProvider{BaseDir: "mocks"},
+		DryRun: true,
+	}
+
+	files, err := Generate(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "Service", files[0].Interface.Name)
+
+	opts.IncludeTests = true
+	files, err = Generate(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, "Client", files[0].Interface.Name)
+	assert.Equal(t, filepath.Join(dir, "mock_Client_test.go"), files[0].Path)
+	assert.Contains(t, string(files[0].Content), "\npackage testpkg_test\n")
+	assert.Contains(t, string(files[0].Content), "\"github.com/namely/mockery/mockery/fixtures/testpkg\"")
+
+	assert.Equal(t, "Clock", files[1].Interface.Name)
+	assert.Equal(t, filepath.Join(dir, "mock_Clock_test.go"), files[1].Path)
+	assert.Contains(t, string(files[1].Content), "\npackage testpkg\n")
+	assert.Contains(t, string(files[1].Content), "type MockClock struct {")
+
+	assert.Equal(t, "Service", files[2].Interface.Name)
+	assert.Equal(t, filepath.Join("mocks", "Service.go"), files[2].Path)
+}
+
+func TestGenerateIncludeTestsKeepTree(t *testing.T) {
+	dir := getFixturePath("testpkg")
+	files, err := Generate(context.Background(), Options{
+		Dir:          dir,
+		IncludeTests: true,
+		Osp: &FileOutputStreamProvider{
+			BaseDir:                   "mocks",
+			KeepTree:                  true,
+			KeepTreeOriginalDirectory: fixturePath,
+		},
+		DryRun: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, filepath.Join(dir, "mock_Client_test.go"), files[0].Path, "Mocks of test files stay in their package.")
+	assert.Equal(t, filepath.Join(dir, "mock_Clock_test.go"), files[1].Path)
+	assert.Equal(t, filepath.Join("mocks", "testpkg", "Service.go"), files[2].Path)
+}
+
+func TestGenerateIncludeTestsMirror(t *testing.T) {
+	dir := getFixturePath("testpkg")
+	files, err := Generate(context.Background(), Options{
+		Dir:          dir,
+		IncludeTests: true,
+		PackageName:  "{{.PackageName}}mocks",
+		Osp:          &FileOutputStreamProvider{BaseDir: "mocks", Mirror: true},
+		DryRun:       true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, filepath.Join(dir, "mock_Client_test.go"), files[0].Path, "Mocks of test files stay in their package.")
+	assert.Equal(t, filepath.Join(dir, "mock_Clock_test.go"), files[1].Path)
+	assert.Contains(t, string(files[1].Content), "\npackage testpkg\n")
+	assert.Equal(t, filepath.Join("mocks", "mockery", "fixtures", "testpkg", "Service.go"), files[2].Path)
+	assert.Contains(t, string(files[2].Content), "\npackage testpkgmocks\n")
+}
+
+func TestGenerateIncludeTestsFileNameTemplate(t *testing.T) {
+	dir := getFixturePath("testpkg")
+	files, err := Generate(context.Background(), Options{
+		Dir:          dir,
+		IncludeTests: true,
+		Osp:          &FileOutputStreamProvider{BaseDir: "mocks", FileName: "{{.InterfaceName | snakecase}}_mock.go"},
+		DryRun:       true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, filepath.Join(dir, "client_mock_test.go"), files[0].Path, "Mocks of test files are test files.")
+	assert.Equal(t, filepath.Join(dir, "clock_mock_test.go"), files[1].Path)
+	assert.Equal(t, filepath.Join("mocks", "service_mock.go"), files[2].Path)
+}
+
+func TestGeneratePackageFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logger := &recordingLogger{}
+	files, err := Generate(context.Background(), Options{
+		Dir:          fixturePath,
+		Filter:       regexp.MustCompile("^(Example|ExampleAliasConflict|Requester)$"),
+		PackageFiles: true,
+		Osp:          &FileOutputStreamProvider{BaseDir: dir},
+		Logger:       logger,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	path := filepath.Join(dir, "test_mocks.go")
+	assert.Equal(t, path, files[0].Path)
+	require.Len(t, files[0].Interfaces, 3)
+	assert.Equal(t, files[0].Interfaces[0], files[0].Interface)
+
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, files[0].Content, written)
+	for _, iface := range files[0].Interfaces {
+		assert.Contains(t, string(written), fmt.Sprintf("type %s struct {", iface.Name))
+	}
+	assert.Equal(t, []string{"Generating mocks for: Example, ExampleAliasConflict, Requester in file: " + path}, logger.lines)
+}
+
+func TestGeneratePackageFilesIncludeTests(t *testing.T) {
+	dir := getFixturePath("testpkg")
+	files, err := Generate(context.Background(), Options{
+		Dir:          dir,
+		IncludeTests: true,
+		PackageFiles: true,
+		Osp:          &FileOutputStreamProvider{BaseDir: "mocks"},
+		DryRun:       true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 3, "Mocks of test files and external test packages have their own file.")
+
+	assert.Equal(t, filepath.Join(dir, "testpkg_test_mocks_test.go"), files[0].Path)
+	assert.Equal(t, filepath.Join(dir, "testpkg_mocks_test.go"), files[1].Path)
+	assert.Equal(t, filepath.Join("mocks", "testpkg_mocks.go"), files[2].Path)
+}
+
+func TestPackageFilesConflict(t *testing.T) {
+	visitor := &collectingVisitor{GeneratorVisitor: &GeneratorVisitor{
+		Osp: &FileOutputStreamProvider{BaseDir: "mocks"},
+	}}
+	a := &Interface{Name: "Store", QualifiedName: "example.com/a/store", FileName: "a/store/store.go", Pkg: types.NewPackage("example.com/a/store", "store")}
+	b := &Interface{Name: "Cache", QualifiedName: "example.com/b/store", FileName: "b/store/cache.go", Pkg: types.NewPackage("example.com/b/store", "store")}
+
+	_, err := visitor.groupPackageFiles([]*Interface{a, b})
+	require.Error(t, err)
+	assert.Equal(t, "mocks of example.com/a/store.Store and example.com/b/store.Cache would both be written to "+filepath.Join("mocks", "store_mocks.go"), err.Error())
+
+	pkgFiles, err := visitor.groupPackageFiles([]*Interface{a, {Name: "Cache", QualifiedName: a.QualifiedName, FileName: "a/store/cache.go", Pkg: a.Pkg}})
+	require.NoError(t, err)
+	require.Len(t, pkgFiles, 1)
+	assert.Len(t, pkgFiles[0].ifaces, 2)
+}
+
+func TestGenerateWithMockNameTemplate(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:      fixturePath,
+		Filter:   regexp.MustCompile("^(requester_unexported|DirectiveStore)$"),
+		MockName: "Mock{{.InterfaceName | firstUpper}}",
+		Osp:      &FileOutputStreamProvider{BaseDir: "mocks", FileName: "{{.InterfaceName | snakecase}}_mock.go"},
+		DryRun:   true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, filepath.Join("mocks", "directive_store_mock.go"), files[0].Path)
+	assert.Contains(t, string(files[0].Content), "type FakeStore struct {", "Directives take precedence.")
+
+	assert.Equal(t, filepath.Join("mocks", "requester_unexported_mock.go"), files[1].Path)
+	assert.Contains(t, string(files[1].Content), "type MockRequester_unexported struct {")
+}
+
+func TestGenerateWithPackageNameTemplate(t *testing.T) {
+	files, err := Generate(context.Background(), Options{
+		Dir:         getFixturePath("testpkg"),
+		PackageName: "{{.PackageName}}mocks",
+		Osp:         &FileOutputStreamProvider{BaseDir: "mocks", Mirror: true},
+		DryRun:      true,
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	assert.Equal(t, filepath.Join("mocks", "mockery", "fixtures", "testpkg", "Service.go"), files[0].Path)
+	assert.Contains(t, string(files[0].Content), "\npackage testpkgmocks\n")
+
+	_, err = Generate(context.Background(), Options{
+		Dir:         getFixturePath("testpkg"),
+		PackageName: "{{.PackageName}}-mocks",
+	})
+	assert.Error(t, err)
+}
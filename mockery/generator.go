@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"go/ast"
 	"go/build"
+	"go/token"
 	"go/types"
 	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -26,20 +27,63 @@ var invalidIdentifierChar = regexp.MustCompile("[^[:digit:][:alpha:]_]")
 type Generator struct {
 	buf bytes.Buffer
 
-	ip    bool
-	iface *Interface
-	pkg   string
+	ip bool
+	// The interfaces mocked in the file, sharing its imports, and the one
+	// currently being rendered
+	ifaces []*Interface
+	iface  *Interface
+	pkg    string
 
 	importsWerePopulated bool
 	localizationCache    map[string]string
 	packagePathToName    map[string]string
 	nameToPackagePath    map[string]string
+	sourceAliases        map[string]string
 
 	packageRoots []string
+	// Whether the interfaces were loaded in GOPATH mode
+	gopath bool
+
+	// The method and parameter currently being rendered, used to position
+	// errors, and the first error encountered.
+	method *types.Func
+	param  *types.Var
+	kind   string
+	index  int
+	err    error
+
+	// ExpandAliases renders type aliases as the type they stand for
+	// instead of by the name they were declared with.
+	ExpandAliases bool
+	// Fingerprint is written in the prologue by GeneratePrologueNote when
+	// set, see GeneratorVisitor.
+	Fingerprint string
+	// MockName replaces the name of the mock type when set, and the one
+	// given by the //mockery:name directive. Only meant for a single
+	// interface.
+	MockName string
+	// MockNames replaces the names of the mock types of the interfaces it
+	// has, and the ones given by //mockery:name directives.
+	MockNames map[*Interface]string
+	// Lenient mocks return zero values for the results an expectation
+	// doesn't provide instead of panicking. Interfaces with the
+	// //mockery:lenient directive always are.
+	Lenient bool
 }
 
 // NewGenerator builds a Generator.
 func NewGenerator(iface *Interface, pkg string, inPackage bool) *Generator {
+	var ifaces []*Interface
+	if iface != nil {
+		ifaces = []*Interface{iface}
+	}
+	return NewPackageGenerator(ifaces, pkg, inPackage)
+}
+
+// NewPackageGenerator builds a Generator for the mocks of several interfaces
+// of the same package in a single file. Their imports are merged, and
+// de-conflicted, by GeneratePrologue.
+func NewPackageGenerator(ifaces []*Interface, pkg string, inPackage bool) *Generator {
 	var roots []string
 
 	for _, root := range filepath.SplitList(build.Default.GOPATH) {
@@ -47,7 +91,7 @@ func NewGenerator(iface *Interface, pkg string, inPackage bool) *Generator {
 	}
 
 	g := &Generator{
-		iface:             iface,
+		ifaces:            ifaces,
 		pkg:               pkg,
 		ip:                inPackage,
 		localizationCache: make(map[string]string),
@@ -55,43 +99,93 @@ func NewGenerator(iface *Interface, pkg string, inPackage bool) *Generator {
 		nameToPackagePath: make(map[string]string),
 		packageRoots:      roots,
 	}
+	if len(ifaces) > 0 {
+		g.iface = ifaces[0]
+		g.gopath = g.iface.gopath
+	}
+	g.sourceAliases = sourceImportAliases(g.iface)
 
 	g.addPackageImportWithName("github.com/stretchr/testify/mock", "mock")
 	return g
 }
 
+// populateImports adds the imports of every interface. A package imported
+// by several of them keeps the name it was first given.
 func (g *Generator) populateImports() {
-	if g.importsWerePopulated {
+	if g.importsWerePopulated || len(g.ifaces) == 0 {
 		return
 	}
-	for i := 0; i < g.iface.Type.NumMethods(); i++ {
-		fn := g.iface.Type.Method(i)
-		ftype := fn.Type().(*types.Signature)
-		g.addImportsFromTuple(ftype.Params())
-		g.addImportsFromTuple(ftype.Results())
-		g.renderType(g.iface.NamedType)
+	for _, iface := range g.ifaces {
+		g.iface = iface
+		g.sourceAliases = sourceImportAliases(iface)
+		for i := 0; i < iface.Type.NumMethods(); i++ {
+			fn := iface.Type.Method(i)
+			ftype := fn.Type().(*types.Signature)
+			g.method = fn
+			g.addImportsFromTuple(ftype.Params(), "parameter")
+			g.addImportsFromTuple(ftype.Results(), "result")
+			g.renderType(iface.NamedType)
+		}
 	}
+	g.method = nil
+	g.iface = g.ifaces[0]
+	g.importsWerePopulated = true
 }
 
-func (g *Generator) addImportsFromTuple(list *types.Tuple) {
+func (g *Generator) addImportsFromTuple(list *types.Tuple, kind string) {
 	for i := 0; i < list.Len(); i++ {
+		g.setParam(kind, i, list.At(i))
 		// We use renderType here because we need to recursively
 		// resolve any types to make sure that all named types that
 		// will appear in the interface file are known
 		g.renderType(list.At(i).Type())
 	}
+	g.setParam("", 0, nil)
+}
+
+func (g *Generator) setParam(kind string, index int, param *types.Var) {
+	g.kind = kind
+	g.index = index
+	g.param = param
 }
 
 func (g *Generator) addPackageImport(pkg *types.Package) string {
 	return g.addPackageImportWithName(pkg.Path(), pkg.Name())
 }
 
+// sourceImportAliases returns the names the file declaring iface imports
+// packages with, by import path.
+func sourceImportAliases(iface *Interface) map[string]string {
+	aliases := make(map[string]string)
+	if iface == nil || iface.File == nil {
+		return aliases
+	}
+
+	for _, spec := range iface.File.Imports {
+		if spec.Name == nil || spec.Name.Name == "_" || spec.Name.Name == "." {
+			continue
+		}
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		aliases[path] = spec.Name.Name
+	}
+	return aliases
+}
+
 func (g *Generator) addPackageImportWithName(path, name string) string {
-	path = g.getLocalizedPath(path)
+	path = g.importPath(path)
 	if existingName, pathExists := g.packagePathToName[path]; pathExists {
 		return existingName
 	}
 
+	// Use the name the interface's file imports the package with, so that the
+	// mock reads like the source. It is still subject to conflict resolution.
+	if alias, ok := g.sourceAliases[path]; ok {
+		name = alias
+	}
+
 	nonConflictingName := g.getNonConflictingName(path, name)
 	g.packagePathToName[path] = nonConflictingName
 	g.nameToPackagePath[nonConflictingName] = path
@@ -150,8 +244,34 @@ func calculateImport(set []string, path string) string {
 	return path
 }
 
-// TODO(@IvanMalison): Is there not a better way to get the actual
-// import path of a package?
+// importPath returns the path to import a package with. Packages are loaded
+// with golang.org/x/tools/go/packages, so the path of a types.Package is its
+// PkgPath, which is the import path in module mode whatever the location of
+// the module, its replace directives or nesting. Only the paths go list
+// reports outside of module mode, for vendored packages ("a/vendor/b") and
+// directories outside of any GOPATH ("_/abs/dir"), go through the
+// localization heuristics.
+func (g *Generator) importPath(path string) string {
+	if strings.HasPrefix(path, "_/") {
+		return g.getLocalizedPath(filepath.FromSlash(path[1:]))
+	}
+	if filepath.IsAbs(path) || strings.HasSuffix(path, ".go") || g.isVendored(path) {
+		return g.getLocalizedPath(path)
+	}
+	return path
+}
+
+// isVendored tells whether path is the path of a vendored package. Packages
+// vendored in GOROOT start with "vendor/" in any mode, but a "vendor"
+// element elsewhere only means vendoring in GOPATH mode: in module mode,
+// vendored packages have their own import path and "example.com/vendor/api"
+// is just a package named that way.
+func (g *Generator) isVendored(path string) bool {
+	return strings.HasPrefix(path, "vendor/") || g.gopath && strings.Contains(path, "/vendor/")
+}
+
+// getLocalizedPath guesses the import path of a package from its directory
+// or GOPATH-mode path, by looking for vendor directories and GOPATH roots.
 func (g *Generator) getLocalizedPath(path string) string {
 	if strings.HasSuffix(path, ".go") {
 		path, _ = filepath.Split(path)
@@ -185,9 +305,24 @@ func (g *Generator) getLocalizedPath(path string) string {
 }
 
 func (g *Generator) mockName() string {
-	if g.ip {
-		if ast.IsExported(g.iface.Name) {
-			return "Mock" + g.iface.Name
+	if g.MockName != "" {
+		return g.MockName
+	}
+	if name := g.MockNames[g.iface]; name != "" {
+		return name
+	}
+	if g.iface.Directives.Name != "" {
+		return g.iface.Directives.Name
+	}
+	return defaultMockName(g.iface.Name, g.ip)
+}
+
+// defaultMockName names the mock of an interface: after the interface, and
+// prefixed with Mock in the interface's package.
+func defaultMockName(name string, inPackage bool) string {
+	if inPackage {
+		if ast.IsExported(name) {
+			return "Mock" + name
 		}
 		first := true
 		return "mock" + strings.Map(func(r rune) rune {
@@ -196,10 +331,10 @@ func (g *Generator) mockName() string {
 				return unicode.ToUpper(r)
 			}
 			return r
-		}, g.iface.Name)
+		}, name)
 	}
 
-	return g.iface.Name
+	return name
 }
 
 func (g *Generator) sortedImportNames() (importNames []string) {
@@ -239,6 +374,9 @@ func (g *Generator) GeneratePrologue(pkg string) {
 // string.
 func (g *Generator) GeneratePrologueNote(note string) {
 	g.printf("// Code generated by mockery v%s. DO NOT EDIT.\n", SemVer)
+	if g.Fingerprint != "" {
+		g.printf("%s%s\n", fingerprintHeader, g.Fingerprint)
+	}
 	if note != "" {
 		g.printf("\n")
 		for _, n := range strings.Split(note, "\\n") {
@@ -252,6 +390,67 @@ func (g *Generator) GeneratePrologueNote(note string) {
 // type.
 var ErrNotInterface = errors.New("expression not an interface")
 
+// GenerateError is returned when a mock can't be generated for an interface.
+// It carries the method and parameter that couldn't be rendered and the
+// position of their declaration.
+type GenerateError struct {
+	Interface string
+	Method    string
+	Param     string
+	Position  token.Position
+	Msg       string
+}
+
+func (e *GenerateError) Error() string {
+	var b strings.Builder
+	if e.Position.IsValid() {
+		b.WriteString(e.Position.String() + ": ")
+	}
+	b.WriteString(e.Interface)
+	if e.Method != "" {
+		b.WriteString("." + e.Method)
+	}
+	if e.Param != "" {
+		b.WriteString(" " + e.Param)
+	}
+	b.WriteString(": " + e.Msg)
+	return b.String()
+}
+
+// fail records an error for the declaration currently being rendered. Only
+// the first error is kept, as later ones are usually a consequence of it.
+func (g *Generator) fail(format string, args ...interface{}) {
+	if g.err != nil {
+		return
+	}
+
+	err := &GenerateError{
+		Interface: g.iface.QualifiedName + "." + g.iface.Name,
+		Msg:       fmt.Sprintf(format, args...),
+	}
+
+	pos := g.iface.NamedType.Obj().Pos()
+	if g.method != nil {
+		err.Method = g.method.Name()
+		pos = g.method.Pos()
+	}
+	if g.param != nil {
+		if g.param.Name() != "" {
+			err.Param = fmt.Sprintf("%s %s", g.kind, g.param.Name())
+		} else {
+			err.Param = fmt.Sprintf("%s #%d", g.kind, g.index)
+		}
+		if g.param.Pos().IsValid() {
+			pos = g.param.Pos()
+		}
+	}
+	if g.iface.Fset != nil {
+		err.Position = g.iface.Fset.Position(pos)
+	}
+
+	g.err = err
+}
+
 func (g *Generator) printf(s string, vals ...interface{}) {
 	fmt.Fprintf(&g.buf, s, vals...)
 }
@@ -261,6 +460,10 @@ type namer interface {
 }
 
 func (g *Generator) renderType(typ types.Type) string {
+	if alias, ok := g.renderAlias(typ); ok {
+		return alias
+	}
+
 	switch t := typ.(type) {
 	case *types.Named:
 		o := t.Obj()
@@ -311,32 +514,67 @@ func (g *Generator) renderType(typ types.Type) string {
 			return "chan<- " + g.renderType(t.Elem())
 		}
 	case *types.Struct:
-		var fields []string
-
-		for i := 0; i < t.NumFields(); i++ {
-			f := t.Field(i)
-
-			if f.Anonymous() {
-				fields = append(fields, g.renderType(f.Type()))
-			} else {
-				fields = append(fields, fmt.Sprintf("%s %s", f.Name(), g.renderType(f.Type())))
-			}
-		}
-
-		return fmt.Sprintf("struct{%s}", strings.Join(fields, ";"))
+		return g.renderStruct(t)
 	case *types.Interface:
 		if t.NumMethods() != 0 {
-			panic("Unable to mock inline interfaces with methods")
+			g.fail("unable to mock inline interfaces with methods")
 		}
 
 		return "interface{}"
 	case namer:
 		return t.Name()
 	default:
-		panic(fmt.Sprintf("un-namable type: %#v (%T)", t, t))
+		g.fail("un-namable type: %s (%T)", t, t)
+		return ""
 	}
 }
 
+// renderStruct renders a struct literal type so that it is identical to the
+// one declared in the interface: field tags are kept, embedded fields are
+// rendered by their (possibly pointer) type, and unexported fields are only
+// allowed when the mock lives in the package that declares them.
+func (g *Generator) renderStruct(t *types.Struct) string {
+	if t.NumFields() == 0 {
+		return "struct{}"
+	}
+
+	fields := make([]string, 0, t.NumFields())
+	for i := 0; i < t.NumFields(); i++ {
+		f := t.Field(i)
+
+		if !f.Exported() && !(g.ip && f.Pkg() == g.iface.Pkg) {
+			g.fail(
+				"unable to mock struct literal with unexported field %s outside of package %s",
+				f.Name(), f.Pkg().Path(),
+			)
+		}
+
+		var field string
+		if f.Anonymous() {
+			field = g.renderType(f.Type())
+		} else {
+			field = f.Name() + " " + g.renderType(f.Type())
+		}
+
+		if tag := t.Tag(i); tag != "" {
+			field += " " + quoteTag(tag)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fmt.Sprintf("struct{ %s }", strings.Join(fields, "; "))
+}
+
+// quoteTag renders a struct tag as a raw string literal when possible, which
+// is how tags are written by hand, and falls back to an interpreted literal.
+func quoteTag(tag string) string {
+	if strings.ContainsAny(tag, "`\r") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
+
 func (g *Generator) renderTypeTuple(tup *types.Tuple) string {
 	var parts []string
 
@@ -350,7 +588,7 @@ func (g *Generator) renderTypeTuple(tup *types.Tuple) string {
 }
 
 func isNillable(typ types.Type) bool {
-	switch t := typ.(type) {
+	switch t := unalias(typ).(type) {
 	case *types.Pointer, *types.Array, *types.Map, *types.Interface, *types.Signature, *types.Chan, *types.Slice:
 		return true
 	case *types.Named:
@@ -367,15 +605,17 @@ type paramList struct {
 	Variadic bool
 }
 
-func (g *Generator) genList(list *types.Tuple, variadic bool) *paramList {
+func (g *Generator) genList(list *types.Tuple, variadic bool, kind string) *paramList {
 	var params paramList
 
 	if list == nil {
 		return &params
 	}
+	defer g.setParam("", 0, nil)
 
 	for i := 0; i < list.Len(); i++ {
 		v := list.At(i)
+		g.setParam(kind, i, v)
 
 		ts := g.renderType(v.Type())
 
@@ -386,7 +626,7 @@ func (g *Generator) genList(list *types.Tuple, variadic bool) *paramList {
 				params.Variadic = true
 				ts = "..." + g.renderType(t.Elem())
 			default:
-				panic("bad variadic type!")
+				g.fail("bad variadic type %s", t)
 			}
 		}
 
@@ -417,13 +657,31 @@ func (g *Generator) nameCollides(pname string) bool {
 var ErrNotSetup = errors.New("not setup")
 
 // Generate builds a string that constitutes a valid go source file
-// containing the mock of the relevant interface.
+// containing the mocks of the relevant interfaces.
 func (g *Generator) Generate() error {
 	g.populateImports()
-	if g.iface == nil {
+	if len(g.ifaces) == 0 {
 		return ErrNotSetup
 	}
+	if g.err != nil {
+		return g.err
+	}
+
+	for i, iface := range g.ifaces {
+		if i > 0 {
+			g.printf("\n")
+		}
+		g.iface = iface
+		g.generateMock()
+		if g.err != nil {
+			return g.err
+		}
+	}
+	return nil
+}
 
+// generateMock renders the mock of the current interface.
+func (g *Generator) generateMock() {
 	g.printf(
 		"// %s is an autogenerated mock type for the %s type\n", g.mockName(),
 		g.iface.Name,
@@ -449,14 +707,19 @@ func (g *Generator) Generate() error {
 		ftype := fn.Type().(*types.Signature)
 		fname := fn.Name()
 
-		params := g.genList(ftype.Params(), ftype.Variadic())
-		returns := g.genList(ftype.Results(), false)
+		g.method = fn
+		params := g.genList(ftype.Params(), ftype.Variadic(), "parameter")
+		returns := g.genList(ftype.Results(), false, "result")
 
 		g.mockMethod(fname, params, returns)
 		g.mockMethodExpectation(expectationName, fname, params, returns)
 	}
+	g.method = nil
+}
 
-	return nil
+// lenient tells whether the mock of the current interface is lenient.
+func (g *Generator) lenient() bool {
+	return g.Lenient || g.iface.Directives.Lenient
 }
 
 func (g *Generator) mockMethod(fname string, params, returns *paramList) {
@@ -493,6 +756,12 @@ func (g *Generator) mockMethod(fname string, params, returns *paramList) {
 		var ret []string
 
 		for idx, typ := range returns.Types {
+			if g.lenient() {
+				g.lenientResult(idx, typ, params, formattedParamNames)
+				ret = append(ret, fmt.Sprintf("r%d", idx))
+				continue
+			}
+
 			g.printf("\tvar r%d %s\n", idx, typ)
 			g.printf("\tif rf, ok := ret.Get(%d).(func(%s) %s); ok {\n",
 				idx, strings.Join(params.Types, ", "), typ)
@@ -520,6 +789,21 @@ func (g *Generator) mockMethod(fname string, params, returns *paramList) {
 	g.printf("}\n\n")
 }
 
+// lenientResult assigns result idx from the values the expectation returns,
+// leaving it to its zero value when the expectation has no value of the
+// right type for it.
+func (g *Generator) lenientResult(idx int, typ string, params *paramList, formattedParamNames string) {
+	g.printf("\tvar r%d %s\n", idx, typ)
+	g.printf("\tif len(ret) > %d {\n", idx)
+	g.printf("\t\tif rf, ok := ret.Get(%d).(func(%s) %s); ok {\n",
+		idx, strings.Join(params.Types, ", "), typ)
+	g.printf("\t\t\tr%d = rf(%s)\n", idx, formattedParamNames)
+	g.printf("\t\t} else if v, ok := ret.Get(%d).(%s); ok {\n", idx, typ)
+	g.printf("\t\t\tr%d = v\n", idx)
+	g.printf("\t\t}\n")
+	g.printf("\t}\n\n")
+}
+
 func (g *Generator) mockMethodExpectation(expectationName, fname string, params, returns *paramList) {
 	methodExpectationName := g.mockName() + fname + "Expectation"
 	g.printf("type %s struct {\n\tcall *mock.Call\n}\n\n", methodExpectationName)
@@ -679,13 +963,15 @@ func (g *Generator) varargs(list *paramList) string {
 }
 
 func (g *Generator) Write(w io.Writer) error {
+	if g.err != nil {
+		return g.err
+	}
+
 	opt := &imports.Options{Comments: true}
 	theBytes := g.buf.Bytes()
 
 	res, err := imports.Process("mock.go", theBytes, opt)
 	if err != nil {
-		line := "--------------------------------------------------------------------------------------------"
-		fmt.Fprintf(os.Stderr, "Between the lines is the file (mock.go) mockery generated in-memory but detected as invalid:\n%s\n%s\n%s\n", line, g.buf.String(), line)
 		return err
 	}
 
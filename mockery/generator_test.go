@@ -2,6 +2,7 @@ package mockery
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"go/format"
 	"io/ioutil"
@@ -52,6 +53,13 @@ func (s *GeneratorSuite) checkGeneration(
 	filepath, interfaceName string, inPackage bool, expected string,
 ) *Generator {
 	generator := s.getGenerator(filepath, interfaceName, inPackage)
+	s.checkGenerator(generator, expected)
+	return generator
+}
+
+// checkGenerator runs a generator configured by the test and compares its
+// output with expected.
+func (s *GeneratorSuite) checkGenerator(generator *Generator, expected string) {
 	s.NoError(generator.Generate(), "The generator ran without errors.")
 
 	// Mirror the formatting done by normally done by golang.org/x/tools/imports in Generator.Write.
@@ -72,7 +80,6 @@ func (s *GeneratorSuite) checkGeneration(
 		expectedLines, actualLines,
 		"The generator produced the expected output.",
 	)
-	return generator
 }
 
 func (s *GeneratorSuite) checkPrologueGeneration(
@@ -93,6 +100,24 @@ func (s *GeneratorSuite) TestCalculateImport() {
 	s.Equal("d/src/c", calculateImport(gp, "d/src/c"))
 }
 
+func (s *GeneratorSuite) TestImportPath() {
+	gen := s.getGenerator(testFile, "Requester", false)
+	gen.packageRoots = []string{"/go/src"}
+	s.False(gen.gopath, "Fixtures are loaded in module mode.")
+
+	// Module paths are used as is, whether or not they look like a GOPATH.
+	s.Equal("github.com/namely/mockery/mockery/fixtures", gen.importPath("github.com/namely/mockery/mockery/fixtures"))
+	s.Equal("example.com/go/src/pkg", gen.importPath("example.com/go/src/pkg"))
+	s.Equal("net/http", gen.importPath("net/http"))
+	s.Equal("github.com/acme/vendor/api", gen.importPath("github.com/acme/vendor/api"))
+
+	// GOPATH-mode paths fall back to the localization heuristics.
+	gen.gopath = true
+	s.Equal("github.com/pkg/errors", gen.importPath("example.com/app/vendor/github.com/pkg/errors"))
+	s.Equal("example.com/app/pkg", gen.importPath("_/go/src/example.com/app/pkg"))
+	s.Equal("example.com/app/pkg", gen.importPath("/go/src/example.com/app/pkg"))
+}
+
 func (s *GeneratorSuite) TestGenerator() {
 	expected := `// Requester is an autogenerated mock type for the Requester type
 type Requester struct {
@@ -145,6 +170,65 @@ func (_e *RequesterGetExpectation) ToReturn(_a0 string, _a1 error) *mock.Call {
 	s.checkGeneration(testFile, "Requester", false, expected)
 }
 
+func (s *GeneratorSuite) TestGeneratorLenient() {
+	expected := `// FakeRequester is an autogenerated mock type for the Requester type
+type FakeRequester struct {
+	mock.Mock
+}
+
+type FakeRequesterExpectation struct {
+	mock *mock.Mock
+}
+
+func (_m *FakeRequester) Expect() *FakeRequesterExpectation {
+	return &FakeRequesterExpectation{mock: &_m.Mock}
+}
+
+// Get provides a mock function with given fields: path
+func (_m *FakeRequester) Get(path string) (string, error) {
+	ret := _m.Called(path)
+
+	var r0 string
+	if len(ret) > 0 {
+		if rf, ok := ret.Get(0).(func(string) string); ok {
+			r0 = rf(path)
+		} else if v, ok := ret.Get(0).(string); ok {
+			r0 = v
+		}
+	}
+
+	var r1 error
+	if len(ret) > 1 {
+		if rf, ok := ret.Get(1).(func(string) error); ok {
+			r1 = rf(path)
+		} else if v, ok := ret.Get(1).(error); ok {
+			r1 = v
+		}
+	}
+
+	return r0, r1
+}
+
+type FakeRequesterGetExpectation struct {
+	call *mock.Call
+}
+
+func (_e *FakeRequesterExpectation) Get(path string) *FakeRequesterGetExpectation {
+	return &FakeRequesterGetExpectation{
+		call: _e.mock.On("Get", path),
+	}
+}
+
+func (_e *FakeRequesterGetExpectation) ToReturn(_a0 string, _a1 error) *mock.Call {
+	return _e.call.Return(_a0, _a1)
+}
+`
+	generator := s.getGenerator(testFile, "Requester", false)
+	generator.MockName = "FakeRequester"
+	generator.Lenient = true
+	s.checkGenerator(generator, expected)
+}
+
 func (s *GeneratorSuite) TestGeneratorSingleReturn() {
 	expected := `// Requester2 is an autogenerated mock type for the Requester2 type
 type Requester2 struct {
@@ -343,9 +427,9 @@ func (s *GeneratorSuite) TestGeneratorPrologueWithMultipleImportsSameName() {
 
 	expected := `package mocks
 
-import fixtureshttp "github.com/namely/mockery/mockery/fixtures/http"
 import http "net/http"
 import mock "github.com/stretchr/testify/mock"
+import my_http "github.com/namely/mockery/mockery/fixtures/http"
 import test "github.com/namely/mockery/mockery/fixtures"
 
 `
@@ -1547,15 +1631,15 @@ func (_e *ExampleAExpectation) ToReturn(_a0 http.Flusher) *mock.Call {
 	return _e.call.Return(_a0)
 }
 
-// B provides a mock function with given fields: _a0
-func (_m *Example) B(_a0 string) fixtureshttp.MyStruct {
-	ret := _m.Called(_a0)
+// B provides a mock function with given fields: fixtureshttp
+func (_m *Example) B(fixtureshttp string) my_http.MyStruct {
+	ret := _m.Called(fixtureshttp)
 
-	var r0 fixtureshttp.MyStruct
-	if rf, ok := ret.Get(0).(func(string) fixtureshttp.MyStruct); ok {
-		r0 = rf(_a0)
+	var r0 my_http.MyStruct
+	if rf, ok := ret.Get(0).(func(string) my_http.MyStruct); ok {
+		r0 = rf(fixtureshttp)
 	} else {
-		r0 = ret.Get(0).(fixtureshttp.MyStruct)
+		r0 = ret.Get(0).(my_http.MyStruct)
 	}
 
 	return r0
@@ -1565,13 +1649,13 @@ type ExampleBExpectation struct {
 	call *mock.Call
 }
 
-func (_e *ExampleExpectation) B(_a0 string) *ExampleBExpectation {
+func (_e *ExampleExpectation) B(fixtureshttp string) *ExampleBExpectation {
 	return &ExampleBExpectation{
-		call: _e.mock.On("B", _a0),
+		call: _e.mock.On("B", fixtureshttp),
 	}
 }
 
-func (_e *ExampleBExpectation) ToReturn(_a0 fixtureshttp.MyStruct) *mock.Call {
+func (_e *ExampleBExpectation) ToReturn(_a0 my_http.MyStruct) *mock.Call {
 	return _e.call.Return(_a0)
 }
 `
@@ -1581,6 +1665,50 @@ func (_e *ExampleBExpectation) ToReturn(_a0 fixtureshttp.MyStruct) *mock.Call {
 	)
 }
 
+func (s *GeneratorSuite) TestPrologueWithConflictingSourceAlias() {
+	generator := s.getGenerator("same_name_imports_conflict.go", "ExampleAliasConflict", false)
+	expected := `package mocks
+
+import http "github.com/namely/mockery/mockery/fixtures/http"
+import mock "github.com/stretchr/testify/mock"
+import test "github.com/namely/mockery/mockery/fixtures"
+
+`
+	s.checkPrologueGeneration(generator, expected)
+}
+
+func (s *GeneratorSuite) TestPackageGeneratorPrologue() {
+	conflict := s.getInterfaceFromFile("same_name_imports_conflict.go", "ExampleAliasConflict")
+	example := s.getInterfaceFromFile("same_name_imports.go", "Example")
+	generator := NewPackageGenerator([]*Interface{conflict, example}, pkg, false)
+
+	// The first alias of fixtures/http is kept, and net/http is renamed as it
+	// now conflicts with it.
+	expected := `package mocks
+
+import http "github.com/namely/mockery/mockery/fixtures/http"
+import mock "github.com/stretchr/testify/mock"
+import nethttp "net/http"
+import test "github.com/namely/mockery/mockery/fixtures"
+
+`
+	s.checkPrologueGeneration(generator, expected)
+}
+
+func (s *GeneratorSuite) TestPackageGenerator() {
+	requester := s.getInterfaceFromFile(testFile, "Requester")
+	example := s.getInterfaceFromFile("same_name_imports.go", "Example")
+	generator := NewPackageGenerator([]*Interface{requester, example}, pkg, false)
+	generator.GeneratePrologue(pkg)
+	s.Require().NoError(generator.Generate())
+
+	var buf bytes.Buffer
+	s.Require().NoError(generator.Write(&buf), "The file with both mocks is valid.")
+	s.Contains(buf.String(), "type Requester struct {")
+	s.Contains(buf.String(), "func (_m *Example) B(fixtureshttp string) my_http.MyStruct {")
+	s.Less(strings.Index(buf.String(), "type Requester struct {"), strings.Index(buf.String(), "type Example struct {"))
+}
+
 func (s *GeneratorSuite) TestGeneratorWithImportSameAsLocalPackageInpkgNoCycle() {
 	iface := s.getInterfaceFromFile("imports_same_as_package.go", "ImportsSameAsPackage")
 	pkg := iface.QualifiedName
@@ -1771,6 +1899,123 @@ func (_e *ACallExpectation) ToReturn(_a0 test.B, _a1 error) *mock.Call {
 	)
 }
 
+func (s *GeneratorSuite) TestGeneratorForStructLiteral() {
+	expected := `// StructLiteral is an autogenerated mock type for the StructLiteral type
+type StructLiteral struct {
+	mock.Mock
+}
+
+type StructLiteralExpectation struct {
+	mock *mock.Mock
+}
+
+func (_m *StructLiteral) Expect() *StructLiteralExpectation {
+	return &StructLiteralExpectation{mock: &_m.Mock}
+}
+
+// Configure provides a mock function with given fields: opts
+func (_m *StructLiteral) Configure(opts struct {
+	Name    string ` + "`json:\"name\"`" + `
+	Timeout int    ` + "`json:\"timeout,omitempty\"`" + `
+	*test.Options
+	http.Header ` + "`json:\"-\"`" + `
+}) error {
+	ret := _m.Called(opts)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(struct {
+		Name    string ` + "`json:\"name\"`" + `
+		Timeout int    ` + "`json:\"timeout,omitempty\"`" + `
+		*test.Options
+		http.Header ` + "`json:\"-\"`" + `
+	}) error); ok {
+		r0 = rf(opts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type StructLiteralConfigureExpectation struct {
+	call *mock.Call
+}
+
+func (_e *StructLiteralExpectation) Configure(opts struct {
+	Name    string ` + "`json:\"name\"`" + `
+	Timeout int    ` + "`json:\"timeout,omitempty\"`" + `
+	*test.Options
+	http.Header ` + "`json:\"-\"`" + `
+}) *StructLiteralConfigureExpectation {
+	return &StructLiteralConfigureExpectation{
+		call: _e.mock.On("Configure", opts),
+	}
+}
+
+func (_e *StructLiteralConfigureExpectation) ToReturn(_a0 error) *mock.Call {
+	return _e.call.Return(_a0)
+}
+
+// Empty provides a mock function with given fields:
+func (_m *StructLiteral) Empty() struct{} {
+	ret := _m.Called()
+
+	var r0 struct{}
+	if rf, ok := ret.Get(0).(func() struct{}); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(struct{})
+	}
+
+	return r0
+}
+
+type StructLiteralEmptyExpectation struct {
+	call *mock.Call
+}
+
+func (_e *StructLiteralExpectation) Empty() *StructLiteralEmptyExpectation {
+	return &StructLiteralEmptyExpectation{
+		call: _e.mock.On("Empty"),
+	}
+}
+
+func (_e *StructLiteralEmptyExpectation) ToReturn(_a0 struct{}) *mock.Call {
+	return _e.call.Return(_a0)
+}
+`
+	s.checkGeneration(
+		filepath.Join(fixturePath, "struct_literal.go"), "StructLiteral", false,
+		expected,
+	)
+}
+
+func (s *GeneratorSuite) TestGeneratorForStructLiteralUnexportedInPackage() {
+	s.parser = NewParser([]string{"unmockable"})
+	gen := s.getGenerator("unmockable.go", "StructLiteralUnexported", true)
+	s.NoError(gen.Generate())
+	s.Contains(gen.buf.String(), "func (_m *MockStructLiteralUnexported) Get() struct{ name string } {")
+}
+
+func (s *GeneratorSuite) TestGeneratorForStructLiteralUnexportedOutOfPackage() {
+	s.parser = NewParser([]string{"unmockable"})
+	gen := s.getGenerator("unmockable.go", "StructLiteralUnexported", false)
+	err := gen.Generate()
+	s.Require().Error(err)
+
+	genErr, ok := err.(*GenerateError)
+	s.Require().True(ok, "The error is a *GenerateError.")
+	s.Equal("github.com/namely/mockery/mockery/fixtures.StructLiteralUnexported", genErr.Interface)
+	s.Equal("Get", genErr.Method)
+	s.Equal("result #0", genErr.Param)
+	s.Equal(getFixturePath("unmockable.go"), genErr.Position.Filename)
+	s.Equal(9, genErr.Position.Line)
+	s.Contains(err.Error(), "unmockable.go:9:8:")
+	s.Contains(err.Error(), "unexported field name")
+
+	s.Equal(err, gen.Write(ioutil.Discard), "Write refuses to output an invalid mock.")
+}
+
 func TestGeneratorSuite(t *testing.T) {
 	generatorSuite := new(GeneratorSuite)
 	suite.Run(t, generatorSuite)
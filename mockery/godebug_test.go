@@ -0,0 +1,9 @@
+//go:build go1.22
+// +build go1.22
+
+// The tests of aliases need the type checker to record them, see
+// cmd/mockery/godebug.go.
+
+//go:debug gotypesalias=1
+
+package mockery
@@ -0,0 +1,481 @@
+package mockery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goGeneratePrefix starts the //go:generate lines running mockery, those
+// UpdateDirectives manages.
+const goGeneratePrefix = "//go:generate mockery "
+
+// DirectiveChange is a //go:generate line inserted, updated or removed by
+// UpdateDirectives.
+type DirectiveChange struct {
+	Interface *Interface
+	// The file changed, and the line of the change in the file as it was
+	Path string
+	Line int
+	// The line before and after the change. Old is empty when the line is
+	// inserted, and New when it is removed.
+	Old string
+	New string
+}
+
+// DirectivesOptions configures UpdateDirectives.
+type DirectivesOptions struct {
+	Options
+	// Remove the lines of the selected interfaces instead
+	Remove bool
+	// Only select the interfaces Config lists, see Config.Lists
+	Configured bool
+}
+
+// UpdateDirectives walks like Generate and, above each selected interface,
+// inserts or updates the //go:generate line running mockery for it alone,
+// with the settings its mock gets from opts, or removes it. Lines are found
+// by the -name they give, anywhere in the file of the interface. Files are
+// rewritten in place, leaving everything but these lines as it is, unless
+// DryRun is set.
+func UpdateDirectives(ctx context.Context, opts DirectivesOptions) ([]DirectiveChange, error) {
+	if opts.PackageFiles {
+		return nil, errors.New("the mocks of a package can't share a file when generated one interface at a time")
+	}
+	if _, ok := opts.Osp.(*FileOutputStreamProvider); !ok {
+		return nil, errors.New("directives can only be written for mocks written to files")
+	}
+	if opts.Configured && opts.Config == nil {
+		return nil, errors.New("no configuration file to select interfaces from")
+	}
+
+	walker, visitor, err := newRun(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces interfaceList
+	_, err = walker.WalkContext(ctx, &ifaces)
+	errs, keptGoing := err.(Errors)
+	if err != nil && !keptGoing {
+		return nil, err
+	}
+
+	var selected []*Interface
+	for _, iface := range ifaces {
+		if !opts.Configured || opts.Config.Lists(iface) {
+			selected = append(selected, iface)
+		}
+	}
+	if err := visitor.PlanWalk(selected); err != nil {
+		return nil, err
+	}
+
+	byFile := make(map[string][]*Interface)
+	var paths []string
+	for _, iface := range selected {
+		if _, ok := byFile[iface.FileName]; !ok {
+			paths = append(paths, iface.FileName)
+		}
+		byFile[iface.FileName] = append(byFile[iface.FileName], iface)
+	}
+	sort.Strings(paths)
+
+	var changes []DirectiveChange
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return changes, err
+		}
+		fileChanges, err := updateFileDirectives(visitor, path, byFile[path], opts)
+		if err != nil {
+			if err := walker.fail(&errs, fmt.Errorf("error updating %s: %w", path, err)); err != nil {
+				return changes, err
+			}
+			continue
+		}
+		changes = append(changes, fileChanges...)
+	}
+	return changes, errs.errorOrNil()
+}
+
+// fileEdit replaces the bytes from start to end of a file.
+type fileEdit struct {
+	start, end int
+	text       string
+	change     DirectiveChange
+}
+
+// updateFileDirectives updates the lines of ifaces, all declared in the file
+// at path, through the offsets of their AST in the file.
+func updateFileDirectives(visitor *collectingVisitor, path string, ifaces []*Interface, opts DirectivesOptions) ([]DirectiveChange, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := ifaces[0].File
+	tokFile := ifaces[0].Fset.File(file.Pos())
+	if tokFile == nil || tokFile.Size() != len(content) {
+		return nil, errors.New("the file changed since it was loaded")
+	}
+
+	existing := goGenerateLines(file)
+	var edits []fileEdit
+	// Declarations whose doc comment got a line separating its text from
+	// the inserted lines
+	separated := make(map[*ast.GenDecl]bool)
+	for _, iface := range ifaces {
+		line, err := directiveLine(visitor.visitorFor(iface), iface, opts.BuildTags)
+		if err != nil {
+			return nil, err
+		}
+		comments := existing[iface.Name]
+		decl := typeDecl(file, iface.Name)
+		// insert adds the line to the end of the doc comment of the
+		// declaration of iface, where gofmt keeps directives.
+		insert := func(change DirectiveChange) error {
+			if decl == nil {
+				return fmt.Errorf("unable to find the declaration of %s", iface.Name)
+			}
+			start := lineStart(content, tokFile.Offset(decl.Pos()))
+			if docNeedsSeparator(decl.Doc) && !separated[decl] {
+				edits = append(edits, fileEdit{start: start, end: start, text: "//\n"})
+				separated[decl] = true
+			}
+			edits = append(edits, fileEdit{start: start, end: start, text: line + "\n", change: change})
+			return nil
+		}
+
+		if len(comments) == 0 && !opts.Remove {
+			change := DirectiveChange{Interface: iface, Path: path, New: line}
+			if decl != nil {
+				change.Line = tokFile.Line(decl.Pos())
+			}
+			if err := insert(change); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for i, c := range comments {
+			change := DirectiveChange{Interface: iface, Path: path, Line: tokFile.Line(c.Pos()), Old: c.Text}
+			start, end := tokFile.Offset(c.Pos()), tokFile.Offset(c.End())
+			if i == 0 && !opts.Remove {
+				indented := lineStart(content, start) != start
+				if c.Text == line && !indented {
+					continue
+				}
+				change.New = line
+				if !indented {
+					edits = append(edits, fileEdit{start: start, end: end, text: line, change: change})
+					continue
+				}
+				// go generate ignores the line, so it moves above the
+				// declaration.
+				if err := insert(DirectiveChange{}); err != nil {
+					return nil, err
+				}
+			}
+			// Lines after the first one are duplicates, and the first one
+			// may have moved.
+			start, end = wholeLine(content, start, end)
+			start = withSeparator(content, start, end)
+			edits = append(edits, fileEdit{start: start, end: end, change: change})
+		}
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	var buf strings.Builder
+	last := 0
+	var changes []DirectiveChange
+	for _, edit := range edits {
+		buf.Write(content[last:edit.start])
+		buf.WriteString(edit.text)
+		last = edit.end
+		// The insertion of a moved line is told by its removal.
+		if edit.change.Interface != nil {
+			changes = append(changes, edit.change)
+		}
+	}
+	buf.Write(content[last:])
+
+	if opts.DryRun {
+		return changes, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return changes, ioutil.WriteFile(path, []byte(buf.String()), info.Mode())
+}
+
+// goGenerateLines returns the //go:generate mockery lines of file, by the
+// interface they give with -name.
+func goGenerateLines(file *ast.File) map[string][]*ast.Comment {
+	lines := make(map[string][]*ast.Comment)
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if name := goGenerateName(c.Text); name != "" {
+				lines[name] = append(lines[name], c)
+			}
+		}
+	}
+	return lines
+}
+
+// goGenerateName returns the interface a //go:generate mockery line is for,
+// or an empty string when it isn't for a single named interface.
+func goGenerateName(text string) string {
+	if !strings.HasPrefix(text, goGeneratePrefix) {
+		return ""
+	}
+
+	var value string
+	args := strings.Fields(text[len(goGeneratePrefix):])
+	for i, arg := range args {
+		arg = strings.TrimPrefix(arg, "-")
+		if arg == "-name" || arg == "name" {
+			if i+1 < len(args) {
+				value = args[i+1]
+			}
+		} else if strings.HasPrefix(arg, "-name=") || strings.HasPrefix(arg, "name=") {
+			value = arg[strings.Index(arg, "=")+1:]
+		}
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	name, err := ParseInterfaceName(value)
+	if err != nil {
+		return ""
+	}
+	return name.Name
+}
+
+// directiveLine returns the //go:generate line generating the mock of iface
+// as visitor does, from the directory of the file declaring it. Names and
+// paths are resolved, so that the line doesn't depend on the configuration
+// or on the other interfaces of the run.
+func directiveLine(visitor *GeneratorVisitor, iface *Interface, buildTags []string) (string, error) {
+	fop := visitor.Osp.(*FileOutputStreamProvider)
+	path, err := visitor.path(iface)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"-name", iface.Name}
+	if strings.HasSuffix(iface.FileName, "_test.go") {
+		args = append(args, "-include-tests")
+	}
+	var tags []string
+	for _, tag := range buildTags {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 0 {
+		args = append(args, "-tags", strings.Join(tags, " "))
+	}
+
+	if fop.InPackage {
+		args = append(args, "-inpkg")
+	} else {
+		dir, err := filepath.Abs(filepath.Dir(iface.FileName))
+		if err != nil {
+			return "", err
+		}
+		output, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(dir, output)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-output", filepath.ToSlash(rel))
+
+		pkgName, err := visitor.packageName(iface)
+		if err != nil {
+			return "", err
+		}
+		if pkgName != "mocks" {
+			args = append(args, "-outpkg", pkgName)
+		}
+	}
+	if fop.TestOnly {
+		args = append(args, "-testonly")
+	}
+	if fop.Case != "" && fop.Case != "camel" {
+		args = append(args, "-case", fop.Case)
+	}
+
+	plain := &FileOutputStreamProvider{BaseDir: filepath.Dir(path), InPackage: fop.InPackage, TestOnly: fop.TestOnly, Case: fop.Case}
+	if plainPath, err := plain.Path(iface); err != nil {
+		return "", err
+	} else if plainPath != path {
+		args = append(args, "-filename", filepath.Base(path))
+	}
+
+	name, err := visitor.mockName(iface)
+	if err != nil {
+		return "", err
+	}
+	plainName, err := (&GeneratorVisitor{InPackage: visitor.InPackage}).mockName(iface)
+	if err != nil {
+		return "", err
+	}
+	if name != plainName {
+		args = append(args, "-mockname", name)
+	}
+
+	if visitor.Note != "" {
+		args = append(args, "-note", visitor.Note)
+	}
+	if visitor.ExpandAliases {
+		args = append(args, "-expandaliases")
+	}
+
+	for i, arg := range args {
+		args[i] = quoteArg(arg)
+	}
+	return goGeneratePrefix + strings.Join(args, " "), nil
+}
+
+// quoteArg quotes arg, in Go syntax like go generate expects, when it
+// wouldn't be a single argument otherwise.
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\"") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+// typeDecl returns the declaration of the type name in file. For a type
+// spec within a grouped declaration, it is the whole group, as go generate
+// only runs the lines that aren't indented, which gofmt indents within the
+// group.
+func typeDecl(file *ast.File, name string) *ast.GenDecl {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if spec.(*ast.TypeSpec).Name.Name == name {
+				return gen
+			}
+		}
+	}
+	return nil
+}
+
+// docNeedsSeparator tells whether lines added to the end of doc must be
+// separated from its text by a // line, as gofmt formats directives. Docs
+// already ending with directives have one, and gofmt leaves /* */ comments
+// as they are.
+func docNeedsSeparator(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	last := doc.List[len(doc.List)-1].Text
+	if !strings.HasPrefix(last, "//") {
+		return false
+	}
+	return last != "//" && !isDirective(last[len("//"):])
+}
+
+// isDirective tells whether the text of a // comment is a directive, such as
+// go:generate or mockery:skip, following the rules of go/doc/comment.
+func isDirective(text string) bool {
+	for _, prefix := range []string{"line ", "extern ", "export "} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+
+	colon := strings.Index(text, ":")
+	if colon <= 0 || colon+1 >= len(text) {
+		return false
+	}
+	for i := 0; i <= colon+1; i++ {
+		if i == colon {
+			continue
+		}
+		if b := text[i]; !('a' <= b && b <= 'z' || '0' <= b && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// withSeparator extends the removal of the whole line from start to end to
+// the // line before it, which separates directives from the text of doc
+// comments, when no comment line is left after it.
+func withSeparator(content []byte, start, end int) int {
+	if start == 0 || lineStart(content, start) != start {
+		return start
+	}
+	next := content[end:]
+	if i := bytes.IndexByte(next, '\n'); i >= 0 {
+		next = next[:i]
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(next)), "//") {
+		return start
+	}
+	prev := lineStart(content, start-1)
+	if strings.TrimSpace(string(content[prev:start])) == "//" {
+		return prev
+	}
+	return start
+}
+
+// lineStart returns the offset of the start of the line holding offset.
+func lineStart(content []byte, offset int) int {
+	start := offset
+	for start > 0 && content[start-1] != '\n' {
+		start--
+	}
+	return start
+}
+
+// wholeLine extends the range from start to end to its whole line, with its
+// line break, when nothing else is on the line. So that removing it doesn't
+// leave two blank lines in a row, or one at the end of the file, which gofmt
+// would remove, the blank line before it goes too in that case.
+func wholeLine(content []byte, start, end int) (int, int) {
+	first := lineStart(content, start)
+	if strings.Trim(string(content[first:start]), " \t") != "" {
+		return start, end
+	}
+	switch {
+	case end == len(content):
+	case content[end] == '\n':
+		end++
+	case content[end] == '\r' && end+1 < len(content) && content[end+1] == '\n':
+		end += 2
+	default:
+		return start, end
+	}
+
+	if first > 0 && (end == len(content) || content[end] == '\n' || content[end] == '\r') {
+		prev := lineStart(content, first-1)
+		if strings.TrimRight(string(content[prev:first]), "\r\n") == "" {
+			return prev, end
+		}
+	}
+	return first, end
+}
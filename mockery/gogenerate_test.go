@@ -0,0 +1,241 @@
+package mockery
+
+import (
+	"context"
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const directivesSource = `package directives
+
+//go:generate mockery -name Store -output ./old
+//go:generate mockery -all
+
+// Store stores.
+type Store interface {
+	Get(key string) string
+}
+
+type (
+	// Queue queues.
+	Queue interface {
+		Push(v string)
+	}
+
+	Other int
+)
+
+type (
+	//go:generate mockery -name Stack -output mocks -mockname MockStack
+	Stack interface {
+		Pop() string
+	}
+)
+
+//go:generate mockery -name Store -output ./old
+
+// Cache caches.
+//
+// It is safe for concurrent use.
+type Cache interface {
+	Get(key string) string
+}
+`
+
+const directivesUpdated = `package directives
+
+//go:generate mockery -name Store -output mocks -mockname MockStore
+//go:generate mockery -all
+
+// Store stores.
+type Store interface {
+	Get(key string) string
+}
+
+//go:generate mockery -name Queue -output mocks -mockname MockQueue
+type (
+	// Queue queues.
+	Queue interface {
+		Push(v string)
+	}
+
+	Other int
+)
+
+//go:generate mockery -name Stack -output mocks -mockname MockStack
+type (
+	Stack interface {
+		Pop() string
+	}
+)
+
+// Cache caches.
+//
+// It is safe for concurrent use.
+//
+//go:generate mockery -name Cache -output mocks -mockname MockCache
+type Cache interface {
+	Get(key string) string
+}
+`
+
+const directivesRemoved = `package directives
+
+//go:generate mockery -all
+
+// Store stores.
+type Store interface {
+	Get(key string) string
+}
+
+type (
+	// Queue queues.
+	Queue interface {
+		Push(v string)
+	}
+
+	Other int
+)
+
+type (
+	Stack interface {
+		Pop() string
+	}
+)
+
+// Cache caches.
+//
+// It is safe for concurrent use.
+type Cache interface {
+	Get(key string) string
+}
+`
+
+func TestUpdateDirectives(t *testing.T) {
+	dir := tempModule(t, map[string]string{"directives.go": directivesSource})
+	path := filepath.Join(dir, "directives.go")
+
+	update := func(remove bool) []DirectiveChange {
+		changes, err := UpdateDirectives(context.Background(), DirectivesOptions{
+			Options: Options{
+				Dir:      dir,
+				MockName: "Mock{{.InterfaceName}}",
+				Osp:      &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+			},
+			Remove: remove,
+		})
+		require.NoError(t, err)
+		return changes
+	}
+
+	assertFormatted := func(content []byte) {
+		formatted, err := format.Source(content)
+		require.NoError(t, err)
+		assert.Equal(t, string(formatted), string(content), "Files are left as gofmt formats them.")
+	}
+	assertFormatted([]byte(directivesSource))
+
+	changes := update(false)
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, directivesUpdated, string(content))
+	assertFormatted(content)
+
+	require.Len(t, changes, 5)
+	assert.Equal(t, "Store", changes[0].Interface.Name)
+	assert.Equal(t, 3, changes[0].Line)
+	assert.Equal(t, "//go:generate mockery -name Store -output ./old", changes[0].Old)
+	assert.Equal(t, "//go:generate mockery -name Store -output mocks -mockname MockStore", changes[0].New)
+	assert.Equal(t, "Queue", changes[1].Interface.Name)
+	assert.Equal(t, 11, changes[1].Line, "Lines of grouped specs go above the group, as go generate ignores indented lines.")
+	assert.Empty(t, changes[1].Old, "The line is inserted.")
+	assert.Equal(t, "Stack", changes[2].Interface.Name)
+	assert.Equal(t, 21, changes[2].Line)
+	assert.Equal(t, changes[2].Old, changes[2].New, "Indented lines move above the group.")
+	assert.Equal(t, 27, changes[3].Line)
+	assert.Empty(t, changes[3].New, "Duplicate lines are removed.")
+	assert.Equal(t, "Cache", changes[4].Interface.Name)
+	assert.Equal(t, 32, changes[4].Line, "Lines go at the end of the doc comment, where gofmt keeps directives.")
+
+	assert.Empty(t, update(false), "Up to date lines are left as they are.")
+
+	assert.Len(t, update(true), 4)
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, directivesRemoved, string(content))
+	assertFormatted(content)
+}
+
+func TestUpdateDirectivesDryRun(t *testing.T) {
+	path := getFixturePath("testdata", "collision", "c", "store.go")
+	before, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	changes, err := UpdateDirectives(context.Background(), DirectivesOptions{
+		Options: Options{
+			Dir:         getFixturePath("testdata", "collision"),
+			Recursive:   true,
+			Names:       []InterfaceName{{Qualifier: "c", Name: "Store"}},
+			PackageName: "{{.PackageName}}mocks",
+			BuildTags:   []string{""},
+			Osp:         &FileOutputStreamProvider{BaseDir: getFixturePath("mocks"), Case: "snake"},
+			DryRun:      true,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, path, changes[0].Path)
+	assert.Equal(t, 4, changes[0].Line)
+	assert.Equal(t, "//go:generate mockery -name Store -output ../../../mocks -outpkg cmocks -case snake", changes[0].New)
+
+	after, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "Files aren't written on a dry run.")
+}
+
+func TestUpdateDirectivesConfigured(t *testing.T) {
+	config := &Config{Packages: map[string]PackageConfig{
+		collisionPkg + "/a/store": {},
+		collisionPkg + "/c":       {Interfaces: map[string]Settings{"Other": {}}},
+	}}
+	changes, err := UpdateDirectives(context.Background(), DirectivesOptions{
+		Options: Options{
+			Dir:       getFixturePath("testdata", "collision"),
+			Recursive: true,
+			Config:    config,
+			Osp:       &FileOutputStreamProvider{InPackage: true},
+			DryRun:    true,
+		},
+		Configured: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, collisionPkg+"/a/store", changes[0].Interface.QualifiedName)
+	assert.Equal(t, "//go:generate mockery -name Store -inpkg", changes[0].New)
+}
+
+func TestGoGenerateName(t *testing.T) {
+	for text, expected := range map[string]string{
+		"//go:generate mockery -name Store":                "Store",
+		"//go:generate mockery -output mocks --name=Store": "Store",
+		`//go:generate mockery -name "store.Store" -inpkg`: "Store",
+		"//go:generate mockery -name Req.*":                "",
+		"//go:generate mockery -name A,B":                  "",
+		"//go:generate mockery -all":                       "",
+		"//go:generate stringer -type Store":               "",
+		"// mockery -name Store":                           "",
+	} {
+		assert.Equal(t, expected, goGenerateName(text), text)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	assert.Equal(t, "mocks", quoteArg("mocks"))
+	assert.Equal(t, `"do not edit"`, quoteArg("do not edit"))
+	assert.Equal(t, `""`, quoteArg(""))
+}
@@ -1,8 +1,12 @@
 package mockery
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 var fixturePath string
@@ -22,3 +26,24 @@ func init() {
 func getFixturePath(subdirOrBasename ...string) string {
 	return filepath.Join(append([]string{fixturePath}, subdirOrBasename...)...)
 }
+
+// tempModulePath is the module path of the modules made by tempModule.
+const tempModulePath = "example.com/tmp"
+
+// tempModule writes files, by slash-separated path, to a new module in a
+// temporary directory, removed at the end of the test, and returns the
+// directory. Tests changing their sources use it rather than writing to
+// fixtures.
+func tempModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	files["go.mod"] = "module " + tempModulePath + "\n\ngo 1.17\n"
+	for name, content := range files {
+		writeTempFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+	return dir
+}
+
+func writeTempFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
@@ -0,0 +1,74 @@
+package mockery
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// InterfaceName names an interface, by its name alone, such as Store, or
+// qualified by the name of its package or the end of its import path, such
+// as store.Store, svc/store.Store or github.com/acme/svc/store.Store.
+type InterfaceName struct {
+	// Empty for a bare name
+	Qualifier string
+	Name      string
+}
+
+// ParseInterfaceName parses a bare or qualified interface name.
+func ParseInterfaceName(s string) (InterfaceName, error) {
+	var n InterfaceName
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		n.Qualifier, n.Name = s[:i], s[i+1:]
+		if err := checkImportPath(n.Qualifier); err != nil {
+			return InterfaceName{}, fmt.Errorf("invalid interface name %q: %s", s, err)
+		}
+	} else {
+		n.Name = s
+	}
+
+	if !token.IsIdentifier(n.Name) {
+		return InterfaceName{}, fmt.Errorf("invalid interface name %q", s)
+	}
+	return n, nil
+}
+
+func (n InterfaceName) String() string {
+	if n.Qualifier == "" {
+		return n.Name
+	}
+	return n.Qualifier + "." + n.Name
+}
+
+// matches tells whether n names iface.
+func (n InterfaceName) matches(iface *Interface) bool {
+	return iface.Name == n.Name && n.qualifies(iface)
+}
+
+// qualifies tells whether the qualifier of n, if any, names the package of
+// iface.
+func (n InterfaceName) qualifies(iface *Interface) bool {
+	switch {
+	case n.Qualifier == "":
+		return true
+	case n.Qualifier == iface.QualifiedName || strings.HasSuffix(iface.QualifiedName, "/"+n.Qualifier):
+		return true
+	default:
+		return iface.Pkg != nil && n.Qualifier == iface.Pkg.Name()
+	}
+}
+
+// AmbiguousNameError is returned when a name, meant to name a single
+// interface, matches several of them.
+type AmbiguousNameError struct {
+	Name       InterfaceName
+	Candidates []*Interface
+}
+
+func (e *AmbiguousNameError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, iface := range e.Candidates {
+		names[i] = iface.QualifiedName + "." + iface.Name
+	}
+	return fmt.Sprintf("%s is ambiguous, it matches %s", e.Name, strings.Join(names, ", "))
+}
@@ -0,0 +1,135 @@
+package mockery
+
+import (
+	"context"
+	"go/types"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInterfaceName(t *testing.T) {
+	for s, expected := range map[string]InterfaceName{
+		"Store":                           {Name: "Store"},
+		"store.Store":                     {Qualifier: "store", Name: "Store"},
+		"github.com/acme/svc/store.Store": {Qualifier: "github.com/acme/svc/store", Name: "Store"},
+		"gopkg.in/yaml.v3.Node":           {Qualifier: "gopkg.in/yaml.v3", Name: "Node"},
+	} {
+		n, err := ParseInterfaceName(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, n, s)
+			assert.Equal(t, s, n.String())
+		}
+	}
+
+	for _, s := range []string{"", "Req.*", ".Store", "store.", "my-iface", "a//b.Store"} {
+		_, err := ParseInterfaceName(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestInterfaceNameMatches(t *testing.T) {
+	iface := &Interface{Name: "Store", QualifiedName: "github.com/acme/svc/store/v2", Pkg: types.NewPackage("github.com/acme/svc/store/v2", "store")}
+
+	for _, s := range []string{"Store", "store.Store", "v2.Store", "store/v2.Store", "github.com/acme/svc/store/v2.Store"} {
+		n, err := ParseInterfaceName(s)
+		require.NoError(t, err)
+		assert.True(t, n.matches(iface), s)
+	}
+	for _, s := range []string{"Other", "svc.Store", "e/v2.Store", "github.com/acme/svc/store.Store"} {
+		n, err := ParseInterfaceName(s)
+		require.NoError(t, err)
+		assert.False(t, n.matches(iface), s)
+	}
+}
+
+func TestGenerateByName(t *testing.T) {
+	generate := func(name string) ([]GeneratedFile, error) {
+		n, err := ParseInterfaceName(name)
+		require.NoError(t, err)
+		return Generate(context.Background(), Options{
+			Dir:       getFixturePath("testdata", "collision"),
+			Recursive: true,
+			Names:     []InterfaceName{n},
+		})
+	}
+
+	files, err := generate("b/store.Store")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, collisionPkg+"/b/store", files[0].Interface.QualifiedName)
+
+	files, err = generate("collision/c.Store")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, collisionPkg+"/c", files[0].Interface.QualifiedName)
+
+	_, err = generate("store.Store")
+	require.Error(t, err)
+	ambiguous, ok := err.(*AmbiguousNameError)
+	require.True(t, ok, "The error is an *AmbiguousNameError.")
+	assert.Len(t, ambiguous.Candidates, 2)
+	assert.Equal(t, "store.Store is ambiguous, it matches "+collisionPkg+"/a/store.Store, "+collisionPkg+"/b/store.Store", err.Error())
+}
+
+func TestGenerateByNamesInSeveralDirs(t *testing.T) {
+	dirs := []string{getFixturePath("testdata", "collision", "a", "store"), getFixturePath("testdata", "collision", "c"), getFixturePath()}
+	generate := func(names ...string) ([]GeneratedFile, error) {
+		var ns []InterfaceName
+		for _, name := range names {
+			n, err := ParseInterfaceName(name)
+			require.NoError(t, err)
+			ns = append(ns, n)
+		}
+		return Generate(context.Background(), Options{Dirs: dirs, Names: ns})
+	}
+
+	files, err := generate("store.Store", "c.Store", "github.com/namely/mockery/mockery/fixtures.Requester")
+	require.NoError(t, err)
+	var generated []string
+	for _, file := range files {
+		generated = append(generated, file.Interface.QualifiedName+"."+file.Interface.Name)
+	}
+	assert.ElementsMatch(t, []string{
+		collisionPkg + "/a/store.Store",
+		collisionPkg + "/c.Store",
+		"github.com/namely/mockery/mockery/fixtures.Requester",
+	}, generated)
+
+	_, err = generate("Requester", "Missing", "b/store.Store")
+	require.Error(t, err)
+	errs, ok := err.(Errors)
+	require.True(t, ok, "Every missing name is reported.")
+	require.Len(t, errs, 2)
+	assert.Equal(t, "unable to find Missing in "+strings.Join(dirs, ", "), errs[0].Error())
+	assert.Equal(t, "unable to find b/store.Store in "+strings.Join(dirs, ", "), errs[1].Error())
+}
+
+func TestGenerateByNameFallback(t *testing.T) {
+	generate := func(name string) ([]GeneratedFile, error) {
+		n, err := ParseInterfaceName(name)
+		require.NoError(t, err)
+		return Generate(context.Background(), Options{
+			Dir:           fixturePath,
+			Names:         []InterfaceName{n},
+			NamesFallback: regexp.MustCompile(name),
+		})
+	}
+
+	files, err := generate("Req.ester")
+	require.NoError(t, err, "Names whose qualifier names no package are regular expressions.")
+	var generated []string
+	for _, file := range files {
+		generated = append(generated, file.Interface.Name)
+	}
+	assert.Contains(t, generated, "Requester")
+	assert.Contains(t, generated, "Requester2")
+
+	files, err = generate("fixtures.Requester")
+	require.NoError(t, err)
+	require.Len(t, files, 1, "Names whose qualifier names a package are names.")
+	assert.Equal(t, "Requester", files[0].Interface.Name)
+}
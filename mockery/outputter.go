@@ -3,7 +3,9 @@ package mockery
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -15,6 +17,20 @@ type OutputStreamProvider interface {
 	GetWriter(iface *Interface) (io.Writer, error, Cleanup)
 }
 
+// pathProvider is implemented by the OutputStreamProviders that write mocks
+// to files, to tell where the mock of an interface goes.
+type pathProvider interface {
+	Path(iface *Interface) (string, error)
+}
+
+// packageOutputStreamProvider is implemented by the OutputStreamProviders
+// that can write the mocks of all the interfaces of a package to a single
+// file. Others get them through GetWriter, for the first interface.
+type packageOutputStreamProvider interface {
+	PackagePath(iface *Interface) (string, error)
+	GetPackageWriter(iface *Interface) (io.Writer, error, Cleanup)
+}
+
 type StdoutStreamProvider struct {
 }
 
@@ -29,48 +45,201 @@ type FileOutputStreamProvider struct {
 	Case                      string
 	KeepTree                  bool
 	KeepTreeOriginalDirectory string
+	// Write each mock to BaseDir followed by the import path of the
+	// interface's package, relative to the module BaseDir is in, so that
+	// the mocks of every package have a package of their own
+	Mirror bool
+	// Template naming the file of each mock, see NameData, instead of Case
+	// and the mock_ prefix of InPackage. Names get the _test.go suffix with
+	// TestOnly.
+	FileName string
+	// Prepended to the interface names files are named after, or in snake
+	// case to the names given by FileName and PackagePath
+	NamePrefix string
 }
 
 func (this *FileOutputStreamProvider) GetWriter(iface *Interface) (io.Writer, error, Cleanup) {
-	var path string
+	path, err := this.Path(iface)
+	if err != nil {
+		return nil, err, func() error { return nil }
+	}
+	return this.create(path)
+}
+
+// GetPackageWriter returns the writer of the file at PackagePath.
+func (this *FileOutputStreamProvider) GetPackageWriter(iface *Interface) (io.Writer, error, Cleanup) {
+	path, err := this.PackagePath(iface)
+	if err != nil {
+		return nil, err, func() error { return nil }
+	}
+	return this.create(path)
+}
+
+func (this *FileOutputStreamProvider) create(path string) (io.Writer, error, Cleanup) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err, func() error { return nil }
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err, func() error { return nil }
+	}
+
+	return f, nil, func() error {
+		return f.Close()
+	}
+}
+
+// Path returns the file the mock of iface is written to.
+func (this *FileOutputStreamProvider) Path(iface *Interface) (string, error) {
+	dir, err := this.dir(iface)
+	if err != nil {
+		return "", err
+	}
+
+	if this.FileName != "" {
+		name, err := executeNameTemplate(this.FileName, iface)
+		if err != nil {
+			return "", err
+		}
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+			return "", fmt.Errorf("invalid file name %q for %s", name, iface.Name)
+		}
+		if this.TestOnly && !strings.HasSuffix(name, "_test.go") {
+			// Only test files can hold the mocks of test files, or use the
+			// test dependencies of TestOnly mocks.
+			name = strings.TrimSuffix(name, ".go") + "_test.go"
+		}
+		return filepath.Join(dir, this.snakePrefix()+name), nil
+	}
 
 	caseName := iface.Name
+	if iface.Directives.Name != "" {
+		caseName = iface.Directives.Name
+	}
+	caseName = this.NamePrefix + caseName
 	if this.Case == "underscore" || this.Case == "snake" {
 		caseName = this.underscoreCaseName(caseName)
 	}
+	return filepath.Join(dir, this.filename(caseName)), nil
+}
+
+// PackagePath returns the file the mocks of all the interfaces of the
+// package declaring iface are written to when they share a file:
+// <package>_mocks.go, or <package>_mocks_test.go with TestOnly, in the
+// directory Path would use.
+func (this *FileOutputStreamProvider) PackagePath(iface *Interface) (string, error) {
+	dir, err := this.dir(iface)
+	if err != nil {
+		return "", err
+	}
+
+	name := this.snakePrefix() + iface.Pkg.Name() + "_mocks"
+	if this.TestOnly {
+		return filepath.Join(dir, name+"_test.go"), nil
+	}
+	return filepath.Join(dir, name+".go"), nil
+}
+
+func (this *FileOutputStreamProvider) snakePrefix() string {
+	if this.NamePrefix == "" {
+		return ""
+	}
+	return snakeCase(this.NamePrefix) + "_"
+}
 
-	if this.KeepTree {
+// dir returns the directory the mock of iface is written to. Mocks that go
+// in the package of their interface are written next to it, whatever
+// KeepTree and Mirror say, as they couldn't compile anywhere else.
+func (this *FileOutputStreamProvider) dir(iface *Interface) (string, error) {
+	if this.InPackage {
+		return filepath.Dir(iface.FileName), nil
+	} else if this.KeepTree {
 		absOriginalDir, err := filepath.Abs(this.KeepTreeOriginalDirectory)
 		if err != nil {
-			return nil, err, func() error { return nil }
-		}
-		relativePath := strings.TrimPrefix(
-			filepath.Join(filepath.Dir(iface.FileName), this.filename(caseName)),
-			absOriginalDir)
-		path = filepath.Join(this.BaseDir, relativePath)
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return nil, err, func() error { return nil }
-		}
-	} else if this.InPackage {
-		path = filepath.Join(filepath.Dir(iface.FileName), this.filename(caseName))
-	} else {
-		path = filepath.Join(this.BaseDir, this.filename(caseName))
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return nil, err, func() error { return nil }
+			return "", err
 		}
+		relativePath := strings.TrimPrefix(filepath.Dir(iface.FileName), absOriginalDir)
+		return filepath.Join(this.BaseDir, relativePath), nil
+	} else if this.Mirror {
+		return this.mirrorDir(iface)
 	}
+	return this.BaseDir, nil
+}
 
-	f, err := os.Create(path)
+// mirrorDir returns BaseDir followed by the import path of the package of
+// iface, without the path of the module BaseDir is in when it's part of it.
+// The directory must be importable from that module.
+func (this *FileOutputStreamProvider) mirrorDir(iface *Interface) (string, error) {
+	base, err := filepath.Abs(this.BaseDir)
 	if err != nil {
-		return nil, err, func() error { return nil }
+		return "", err
+	}
+	root, modulePath, err := findModule(base)
+	if err != nil {
+		return "", err
 	}
 
-	fmt.Printf("Generating mock for: %s in file: %s\n", iface.Name, path)
-	return f, nil, func() error {
-		return f.Close()
+	rel := iface.QualifiedName
+	if rel == modulePath {
+		rel = ""
+	} else if strings.HasPrefix(rel, modulePath+"/") {
+		rel = rel[len(modulePath)+1:]
+	}
+	dir := filepath.Join(base, filepath.FromSlash(rel))
+
+	relDir, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+	importPath := path.Join(modulePath, filepath.ToSlash(relDir))
+	if err := checkImportPath(importPath); err != nil {
+		return "", fmt.Errorf("mirrored directory %s of %s: %s", dir, iface.QualifiedName, err)
+	}
+	return filepath.Join(this.BaseDir, filepath.FromSlash(rel)), nil
+}
+
+// findModule returns the directory and path of the module dir is in.
+func findModule(dir string) (root, modulePath string, err error) {
+	for root = dir; ; root = filepath.Dir(root) {
+		data, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			if m := moduleLine.FindSubmatch(data); m != nil {
+				return root, strings.Trim(string(m[1]), `"`+"`"), nil
+			}
+			return "", "", fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+		} else if !os.IsNotExist(err) {
+			return "", "", err
+		}
+		if filepath.Dir(root) == root {
+			return "", "", fmt.Errorf("%s isn't in a module", dir)
+		}
 	}
 }
 
+var moduleLine = regexp.MustCompile(`(?m)^\s*module\s+(\S+)`)
+
+// checkImportPath reports the elements of an import path the go command
+// would reject, or that it ignores, such as testdata and _ or . prefixed
+// directories.
+func checkImportPath(importPath string) error {
+	for _, elem := range strings.Split(importPath, "/") {
+		switch {
+		case elem == "":
+			return fmt.Errorf("empty element in import path %q", importPath)
+		case elem == "testdata" || strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_"):
+			return fmt.Errorf("import path %q has an ignored element %q", importPath, elem)
+		case strings.IndexFunc(elem, invalidImportPathRune) >= 0:
+			return fmt.Errorf("invalid character in element %q of import path %q", elem, importPath)
+		}
+	}
+	return nil
+}
+
+func invalidImportPathRune(r rune) bool {
+	return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("-._~+", r))
+}
+
 func (this *FileOutputStreamProvider) filename(name string) string {
 	if this.InPackage && this.TestOnly {
 		return "mock_" + name + "_test.go"
@@ -82,8 +251,12 @@ func (this *FileOutputStreamProvider) filename(name string) string {
 	return name + ".go"
 }
 
-// shamelessly taken from http://stackoverflow.com/questions/1175208/elegant-python-function-to-convert-camelcase-to-camel-caseo
 func (this *FileOutputStreamProvider) underscoreCaseName(caseName string) string {
+	return snakeCase(caseName)
+}
+
+// shamelessly taken from http://stackoverflow.com/questions/1175208/elegant-python-function-to-convert-camelcase-to-camel-caseo
+func snakeCase(caseName string) string {
 	rxp1 := regexp.MustCompile("(.)([A-Z][a-z]+)")
 	s1 := rxp1.ReplaceAllString(caseName, "${1}_${2}")
 	rxp2 := regexp.MustCompile("([a-z0-9])([A-Z])")
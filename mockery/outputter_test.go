@@ -1,9 +1,14 @@
 package mockery
 
 import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilenameBare(t *testing.T) {
@@ -34,3 +39,61 @@ func TestUnderscoreCaseName(t *testing.T) {
 	assert.Equal(t, "csv", (&FileOutputStreamProvider{}).underscoreCaseName("CSV"))
 	assert.Equal(t, "position0_size", (&FileOutputStreamProvider{}).underscoreCaseName("Position0Size"))
 }
+
+func TestPathFileNameTemplate(t *testing.T) {
+	iface := &Interface{Name: "NotifyEvent", QualifiedName: "example.com/events", FileName: "/src/events/notify.go", Pkg: types.NewPackage("example.com/events", "events")}
+
+	out := FileOutputStreamProvider{BaseDir: "mocks", FileName: "{{.PackageName}}_{{.InterfaceName | snakecase}}_mock.go"}
+	path, err := out.Path(iface)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("mocks", "events_notify_event_mock.go"), path)
+
+	out.TestOnly = true
+	path, err = out.Path(iface)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("mocks", "events_notify_event_mock_test.go"), path, "Test-only mocks are test files.")
+
+	out.FileName = "{{.InterfaceName}}_test.go"
+	path, err = out.Path(iface)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("mocks", "NotifyEvent_test.go"), path)
+
+	out.FileName = "{{.PackagePath}}.go"
+	_, err = out.Path(iface)
+	assert.EqualError(t, err, `invalid file name "example.com/events.go" for NotifyEvent`)
+}
+
+func TestPathMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.17\n"), 0644))
+
+	store := &Interface{Name: "Store", QualifiedName: "example.com/app/pkg/store", FileName: filepath.Join(dir, "pkg", "store", "store.go")}
+	roundTripper := &Interface{Name: "RoundTripper", QualifiedName: "net/http"}
+
+	out := FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks"), Mirror: true}
+	path, err := out.Path(store)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "mocks", "pkg", "store", "Store.go"), path)
+
+	path, err = out.Path(roundTripper)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "mocks", "net", "http", "RoundTripper.go"), path, "Packages outside of the module are mirrored by import path.")
+
+	out.BaseDir = filepath.Join(dir, "_mocks")
+	_, err = out.Path(store)
+	assert.Error(t, err, "The mocks couldn't be imported.")
+
+	out.BaseDir = os.TempDir()
+	_, err = out.Path(store)
+	assert.Error(t, err, "The mocks aren't in a module.")
+}
+
+func TestCheckImportPath(t *testing.T) {
+	assert.NoError(t, checkImportPath("example.com/app/mocks/pkg/store-v2"))
+	assert.Error(t, checkImportPath("example.com/app/mocks//store"))
+	assert.Error(t, checkImportPath("example.com/app/testdata/store"))
+	assert.Error(t, checkImportPath("example.com/app/.mocks/store"))
+	assert.Error(t, checkImportPath("example.com/app/my mocks/store"))
+}
@@ -3,12 +3,12 @@ package mockery
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
-	"io/ioutil"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
-	"sync"
 
 	"golang.org/x/tools/go/packages"
 )
@@ -18,6 +18,8 @@ type parserEntry struct {
 	pkg        *packages.Package
 	syntax     *ast.File
 	interfaces []string
+	directives map[string]Directives
+	visited    bool
 }
 
 type Parser struct {
@@ -26,6 +28,14 @@ type Parser struct {
 	packages          []*packages.Package
 	parserPackages    []*types.Package
 	conf              packages.Config
+	// Patterns given to Parse and ParsePackage that weren't loaded yet, and
+	// all of them so far
+	pending []string
+	seen    map[string]bool
+	// Whether the go command loads packages in GOPATH mode, found out on
+	// the first load
+	gopath      bool
+	gopathKnown bool
 }
 
 func NewParser(buildTags []string) *Parser {
@@ -44,9 +54,13 @@ func NewParser(buildTags []string) *Parser {
 		parserPackages:    make([]*types.Package, 0),
 		entriesByFileName: map[string]*parserEntry{},
 		conf:              conf,
+		seen:              map[string]bool{},
 	}
 }
 
+// Parse adds the package of the file at path to the ones to load. Packages
+// are loaded all at once by Load, as each call to packages.Load runs the go
+// command and type-checks the dependencies again.
 func (p *Parser) Parse(path string) error {
 	// To support relative paths to mock targets w/ vendor deps, we need to provide eventual
 	// calls to build.Context.Import with an absolute path. It needs to be absolute because
@@ -60,72 +74,121 @@ func (p *Parser) Parse(path string) error {
 		return err
 	}
 
-	dir := filepath.Dir(path)
+	// An absolute directory is a valid package pattern.
+	p.add(filepath.Dir(path))
+	return nil
+}
+
+// ParsePackage adds the package with the given import path to the ones to
+// load. It is resolved along with its dependencies like the go command does
+// from the current directory, e.g. through the module's requirements.
+func (p *Parser) ParsePackage(importPath string) {
+	p.add(importPath)
+}
+
+func (p *Parser) add(pattern string) {
+	if !p.seen[pattern] {
+		p.seen[pattern] = true
+		p.pending = append(p.pending, pattern)
+	}
+}
+
+// loadPending loads the packages added since the last call in a single call
+// to packages.Load. Packages that fail to load are reported and left out.
+func (p *Parser) loadPending() error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	patterns := p.pending
+	p.pending = nil
+
+	if !p.gopathKnown {
+		p.gopath = p.gopathMode()
+		p.gopathKnown = true
+	}
 
-	files, err := ioutil.ReadDir(dir)
+	pkgs, err := packages.Load(&p.conf, patterns...)
 	if err != nil {
 		return err
 	}
 
-	for _, fi := range files {
-		if filepath.Ext(fi.Name()) != ".go" || strings.HasSuffix(fi.Name(), "_test.go") {
-			continue
+	var errs Errors
+	for _, pkg := range pkgs {
+		if err := p.addPackage(pkg); err != nil {
+			errs = append(errs, err)
 		}
+	}
+	return errs.errorOrNil()
+}
 
-		fname := fi.Name()
-		fpath := filepath.Join(dir, fname)
-		if _, ok := p.entriesByFileName[fpath]; ok {
-			continue
-		}
+// gopathMode tells whether the go command runs in GOPATH mode from the
+// directory packages are loaded from, as it then has no main module.
+func (p *Parser) gopathMode() bool {
+	cmd := exec.Command("go", "env", "GOMOD")
+	cmd.Dir = p.conf.Dir
+	cmd.Env = p.conf.Env
+	out, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(out)) == ""
+}
 
-		pkgs, err := packages.Load(&p.conf, "file="+fpath)
-		if err != nil {
-			return err
-		}
-		if len(pkgs) == 0 {
-			continue
-		}
-		if len(pkgs) > 1 {
-			names := make([]string, len(pkgs))
-			for i, p := range pkgs {
-				names[i] = p.Name
-			}
-			panic(fmt.Sprintf("file %s resolves to multiple packages: %s", fpath, strings.Join(names, ", ")))
-		}
+// addPackage records the files of a loaded package that weren't parsed yet.
+func (p *Parser) addPackage(pkg *packages.Package) error {
+	if len(pkg.Errors) > 0 {
+		return pkg.Errors[0]
+	}
+	if len(pkg.GoFiles) == 0 || isTestBinary(pkg) {
+		return nil
+	}
 
-		pkg := pkgs[0]
-		if len(pkg.Errors) > 0 {
-			return pkg.Errors[0]
+	// The test variant of a package, loaded with conf.Tests, repeats the
+	// files of the package itself.
+	testsOnly := isTestVariant(pkg) && !strings.HasSuffix(pkg.Name, "_test")
+
+	for idx, f := range pkg.GoFiles {
+		if _, ok := p.entriesByFileName[f]; ok {
+			continue
 		}
-		if len(pkg.GoFiles) == 0 {
+		if testsOnly && !strings.HasSuffix(f, "_test.go") {
 			continue
 		}
-
-		for idx, f := range pkg.GoFiles {
-			if _, ok := p.entriesByFileName[f]; ok {
-				continue
-			}
-			entry := parserEntry{
-				fileName: f,
-				pkg:      pkg,
-				syntax:   pkg.Syntax[idx],
-			}
-			p.entries = append(p.entries, &entry)
-			p.entriesByFileName[f] = &entry
+		entry := parserEntry{
+			fileName: f,
+			pkg:      pkg,
+			syntax:   pkg.Syntax[idx],
 		}
-		p.packages = append(p.packages, pkg)
+		p.entries = append(p.entries, &entry)
+		p.entriesByFileName[f] = &entry
 	}
-
+	p.packages = append(p.packages, pkg)
 	return nil
 }
 
+// isTestVariant tells whether pkg was compiled for the tests of a package,
+// either the package with its _test.go files or its external _test package.
+// Their IDs look like "example.com/pkg [example.com/pkg.test]".
+func isTestVariant(pkg *packages.Package) bool {
+	return strings.HasSuffix(pkg.ID, ".test]")
+}
+
+// isTestBinary tells whether pkg is the generated main package running the
+// tests of a package.
+func isTestBinary(pkg *packages.Package) bool {
+	return strings.HasSuffix(pkg.ID, ".test") && pkg.Name == "main"
+}
+
 type NodeVisitor struct {
 	declaredInterfaces []string
+	directives         map[string]Directives
+	errs               []directiveError
+	// The declaration of the type specs being visited
+	genDecl *ast.GenDecl
 }
 
 func NewNodeVisitor() *NodeVisitor {
 	return &NodeVisitor{
 		declaredInterfaces: make([]string, 0),
+		directives:         make(map[string]Directives),
 	}
 }
 
@@ -133,43 +196,172 @@ func (n *NodeVisitor) DeclaredInterfaces() []string {
 	return n.declaredInterfaces
 }
 
+// Directives returns the directives of the declared interfaces that have
+// any, by name.
+func (n *NodeVisitor) Directives() map[string]Directives {
+	return n.directives
+}
+
 func (nv *NodeVisitor) Visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
+	case *ast.GenDecl:
+		nv.genDecl = n
 	case *ast.TypeSpec:
 		if _, ok := n.Type.(*ast.InterfaceType); ok {
 			nv.declaredInterfaces = append(nv.declaredInterfaces, n.Name.Name)
+
+			var declDoc *ast.CommentGroup
+			if nv.genDecl != nil {
+				declDoc = nv.genDecl.Doc
+			}
+			directives, errs := parseDirectives(declDoc, n.Doc)
+			if directives != (Directives{}) {
+				nv.directives[n.Name.Name] = directives
+			}
+			nv.errs = append(nv.errs, errs...)
 		}
 	}
 	return nv
 }
 
+// Load loads the packages given to Parse and ParsePackage and finds the
+// interfaces they declare. When some of the packages can't be loaded, the
+// others are still available and every failure is returned as Errors.
 func (p *Parser) Load() error {
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		for _, entry := range p.entries {
-			nv := NewNodeVisitor()
-			ast.Walk(nv, entry.syntax)
-			entry.interfaces = nv.DeclaredInterfaces()
-		}
-		wg.Done()
-	}()
-	wg.Wait()
-	return nil
+	var errs Errors
+	if err := p.loadPending(); err != nil {
+		if loadErrs, ok := err.(Errors); ok {
+			errs = loadErrs
+		} else {
+			return err
+		}
+	}
+
+	for _, entry := range p.entries {
+		if entry.visited {
+			continue
+		}
+		entry.visited = true
+
+		nv := NewNodeVisitor()
+		ast.Walk(nv, entry.syntax)
+		entry.interfaces = nv.DeclaredInterfaces()
+		entry.directives = nv.Directives()
+		for _, err := range nv.errs {
+			errs = append(errs, fmt.Errorf("%s: %s", entry.pkg.Fset.Position(err.pos), err.msg))
+		}
+	}
+	return errs.errorOrNil()
 }
 
+// Invalidate drops the loaded packages of the given absolute directories,
+// or of directories under them, and the loaded packages importing those, so
+// that the next walk loads them again from their current files. The other
+// packages stay loaded, except for those that failed to load, which are
+// loaded again whatever changed as they may have failed because of a
+// package they import.
+func (p *Parser) Invalidate(dirs []string) {
+	under := func(path string) bool {
+		for _, dir := range dirs {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Packages that failed to load are only known by their pattern.
+	loaded := make(map[string]bool, len(p.packages))
+	for _, pkg := range p.packages {
+		loaded[filepath.Dir(pkg.GoFiles[0])] = true
+		loaded[pkg.PkgPath] = true
+	}
+	for pattern := range p.seen {
+		if under(pattern) || !loaded[pattern] {
+			delete(p.seen, pattern)
+		}
+	}
+
+	// Imported packages count too, as the ones that failed to load aren't in
+	// p.packages while those importing them may be.
+	stale := make(map[string]bool)
+	packages.Visit(p.packages, nil, func(pkg *packages.Package) {
+		if len(pkg.GoFiles) > 0 && under(filepath.Dir(pkg.GoFiles[0])) {
+			stale[pkg.PkgPath] = true
+		}
+	})
+	if len(stale) == 0 {
+		return
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, pkg := range p.packages {
+			if stale[pkg.PkgPath] {
+				continue
+			}
+			for _, imported := range pkg.Imports {
+				if stale[imported.PkgPath] {
+					stale[pkg.PkgPath] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var pkgs []*packages.Package
+	for _, pkg := range p.packages {
+		if !stale[pkg.PkgPath] {
+			pkgs = append(pkgs, pkg)
+			continue
+		}
+		// Walks add the directory again if it still has files.
+		delete(p.seen, filepath.Dir(pkg.GoFiles[0]))
+	}
+	p.packages = pkgs
+
+	var entries []*parserEntry
+	for _, entry := range p.entries {
+		if stale[entry.pkg.PkgPath] {
+			delete(p.entriesByFileName, entry.fileName)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	p.entries = entries
+}
+
+// Find returns the interface with the given name, which may be qualified,
+// see ParseInterfaceName. It returns an *AmbiguousNameError when several
+// interfaces have it.
 func (p *Parser) Find(name string) (*Interface, error) {
+	n, err := ParseInterfaceName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*Interface
 	for _, entry := range p.entries {
 		for _, iface := range entry.interfaces {
-			if iface == name {
-				list := p.packageInterfaces(entry.pkg.Types, entry.syntax, entry.fileName, []string{name}, nil)
-				if len(list) > 0 {
-					return list[0], nil
-				}
+			if iface != n.Name {
+				continue
+			}
+			list := p.packageInterfaces(entry.pkg, entry.syntax, entry.fileName, []string{iface}, entry.directives, nil)
+			if len(list) > 0 && n.matches(list[0]) {
+				found = append(found, list[0])
 			}
 		}
 	}
-	return nil, ErrNotInterface
+
+	switch len(found) {
+	case 0:
+		return nil, ErrNotInterface
+	case 1:
+		return found[0], nil
+	default:
+		return nil, &AmbiguousNameError{Name: n, Candidates: found}
+	}
 }
 
 type Interface struct {
@@ -177,9 +369,15 @@ type Interface struct {
 	QualifiedName string
 	FileName      string
 	File          *ast.File
+	Fset          *token.FileSet
 	Pkg           *types.Package
 	Type          *types.Interface
 	NamedType     *types.Named
+	// The //mockery: comments of the declaration
+	Directives Directives
+	// Whether Pkg was loaded in GOPATH mode, where the paths of vendored
+	// packages keep their vendor directory
+	gopath bool
 }
 
 type sortableIFaceList []*Interface
@@ -192,8 +390,16 @@ func (s sortableIFaceList) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
+// Less orders interfaces by name, and same-named ones by package and file,
+// so that the order doesn't depend on how packages were loaded.
 func (s sortableIFaceList) Less(i, j int) bool {
-	return strings.Compare(s[i].Name, s[j].Name) == -1
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+	if s[i].QualifiedName != s[j].QualifiedName {
+		return s[i].QualifiedName < s[j].QualifiedName
+	}
+	return s[i].FileName < s[j].FileName
 }
 
 func (p *Parser) Interfaces() []*Interface {
@@ -201,7 +407,7 @@ func (p *Parser) Interfaces() []*Interface {
 	for _, entry := range p.entries {
 		declaredIfaces := entry.interfaces
 		astFile := entry.syntax
-		ifaces = p.packageInterfaces(entry.pkg.Types, astFile, entry.fileName, declaredIfaces, ifaces)
+		ifaces = p.packageInterfaces(entry.pkg, astFile, entry.fileName, declaredIfaces, entry.directives, ifaces)
 	}
 
 	sort.Sort(ifaces)
@@ -209,11 +415,13 @@ func (p *Parser) Interfaces() []*Interface {
 }
 
 func (p *Parser) packageInterfaces(
-	pkg *types.Package,
+	loaded *packages.Package,
 	file *ast.File,
 	fileName string,
 	declaredInterfaces []string,
+	directives map[string]Directives,
 	ifaces []*Interface) []*Interface {
+	pkg := loaded.Types
 	scope := pkg.Scope()
 	for _, name := range declaredInterfaces {
 		obj := scope.Lookup(name)
@@ -244,6 +452,9 @@ func (p *Parser) packageInterfaces(
 			Type:          iface.Complete(),
 			NamedType:     typ,
 			File:          file,
+			Fset:          loaded.Fset,
+			Directives:    directives[name],
+			gopath:        p.gopath,
 		}
 
 		ifaces = append(ifaces, elem)
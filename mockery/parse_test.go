@@ -1,9 +1,12 @@
 package mockery
 
 import (
+	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -110,3 +113,49 @@ func TestCustomBuildTag(t *testing.T) {
 	}
 	assert.True(t, found, "IfaceWithCustomBuildTagInComment not parsed")
 }
+
+func TestParseLoadsPackagesAtOnce(t *testing.T) {
+	dir := tempModule(t, map[string]string{
+		"store/store.go":   "package store\n\ntype Store interface {\n\tGet(key string) string\n}\n",
+		"store/queue.go":   "package store\n\ntype Queue interface {\n\tPush(v string)\n}\n",
+		"events/events.go": "package events\n\ntype Bus interface {\n\tPublish()\n}\n",
+		"broken/broken.go": "package broken\n\nvar x int = \"\"\n",
+	})
+
+	parser := NewParser(nil)
+	parser.conf.Dir = dir
+	require.NoError(t, parser.Parse(filepath.Join(dir, "store", "store.go")))
+	require.NoError(t, parser.Parse(filepath.Join(dir, "store", "queue.go")))
+	require.NoError(t, parser.Parse(filepath.Join(dir, "events", "events.go")))
+	require.NoError(t, parser.Parse(filepath.Join(dir, "broken", "broken.go")))
+	assert.Len(t, parser.pending, 3, "Files are loaded by directory.")
+
+	err := parser.Load()
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "broken.go:3")
+	assert.Empty(t, parser.pending)
+
+	_, err = parser.Find("Queue")
+	assert.NoError(t, err, "Packages that load are available.")
+	_, err = parser.Find("Bus")
+	assert.NoError(t, err)
+}
+
+func TestFindQualifiedName(t *testing.T) {
+	parser := NewParser(nil)
+	require.NoError(t, parser.Parse(getFixturePath("testdata", "collision", "a", "store", "store.go")))
+	require.NoError(t, parser.Parse(getFixturePath("testdata", "collision", "b", "store", "store.go")))
+	require.NoError(t, parser.Load())
+
+	iface, err := parser.Find("b/store.Store")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/namely/mockery/mockery/fixtures/testdata/collision/b/store", iface.QualifiedName)
+
+	_, err = parser.Find("Store")
+	assert.IsType(t, &AmbiguousNameError{}, err)
+
+	_, err = parser.Find("c.Store")
+	assert.Equal(t, ErrNotInterface, err)
+}
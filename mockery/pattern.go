@@ -0,0 +1,90 @@
+package mockery
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexpPrefix marks a Pattern as a regular expression rather than a glob.
+const regexpPrefix = "re:"
+
+// Pattern matches the paths of directories, relative to the walked one, and
+// the qualified names of interfaces, such as
+// github.com/acme/svc/store.Store. Paths always use forward slashes.
+//
+// Globs follow path.Match. A glob without a slash is matched against the
+// last element of the path or qualified name, e.g. "vendor" or
+// "store.Store", and against the name of interfaces, e.g. "Store". Any other
+// glob is matched against all of it. Regular expressions, given with the
+// "re:" prefix, are matched against all of it.
+type Pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// ParsePattern parses a glob or, with the "re:" prefix, a regular
+// expression.
+func ParsePattern(s string) (Pattern, error) {
+	if strings.HasPrefix(s, regexpPrefix) {
+		re, err := regexp.Compile(s[len(regexpPrefix):])
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid pattern %q: %s", s, err)
+		}
+		return Pattern{re: re}, nil
+	}
+
+	if _, err := path.Match(s, ""); err != nil {
+		return Pattern{}, fmt.Errorf("invalid pattern %q: %s", s, err)
+	}
+	return Pattern{glob: s}, nil
+}
+
+// exactPattern matches the given path and nothing else.
+func exactPattern(s string) Pattern {
+	return Pattern{re: regexp.MustCompile("^" + regexp.QuoteMeta(s) + "$")}
+}
+
+func (p Pattern) String() string {
+	if p.re != nil {
+		return regexpPrefix + p.re.String()
+	}
+	return p.glob
+}
+
+func (p Pattern) match(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+	if !strings.Contains(p.glob, "/") {
+		s = path.Base(s)
+	}
+	ok, _ := path.Match(p.glob, s)
+	return ok
+}
+
+// matchDir tells whether any of patterns matches dir or one of its parents,
+// dir being relative to the walked directory.
+func matchDir(patterns []Pattern, dir string) bool {
+	for ; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		for _, p := range patterns {
+			if p.match(dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchInterface tells whether any of patterns matches the qualified name of
+// iface or, for globs without a slash, its name alone.
+func matchInterface(patterns []Pattern, iface *Interface) bool {
+	name := iface.QualifiedName + "." + iface.Name
+	for _, p := range patterns {
+		if p.match(name) || p.re == nil && !strings.Contains(p.glob, "/") && p.match(iface.Name) {
+			return true
+		}
+	}
+	return false
+}
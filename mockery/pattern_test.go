@@ -0,0 +1,70 @@
+package mockery
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParsePatterns(t *testing.T, values ...string) []Pattern {
+	patterns := make([]Pattern, len(values))
+	for i, value := range values {
+		var err error
+		patterns[i], err = ParsePattern(value)
+		require.NoError(t, err)
+	}
+	return patterns
+}
+
+func TestPatternMatch(t *testing.T) {
+	store := &Interface{Name: "Store", QualifiedName: "github.com/acme/svc/store"}
+
+	assert.True(t, matchDir(mustParsePatterns(t, "vendor"), "vendor/github.com/pkg/errors"))
+	assert.True(t, matchDir(mustParsePatterns(t, "gen/*"), "gen/pb"))
+	assert.False(t, matchDir(mustParsePatterns(t, "gen/*"), "internal/gen/pb"))
+	assert.True(t, matchDir(mustParsePatterns(t, "re:/pb$"), "internal/gen/pb"))
+	assert.False(t, matchDir(mustParsePatterns(t, "vendor"), "."))
+
+	assert.True(t, matchInterface(mustParsePatterns(t, "store.Store"), store))
+	assert.True(t, matchInterface(mustParsePatterns(t, "*.Store"), store))
+	assert.True(t, matchInterface(mustParsePatterns(t, "github.com/acme/*/store.*"), store))
+	assert.True(t, matchInterface(mustParsePatterns(t, `re:^github\.com/acme/`), store))
+	assert.True(t, matchInterface(mustParsePatterns(t, "Store"), store))
+	assert.True(t, matchInterface(mustParsePatterns(t, "St*"), store))
+	assert.False(t, matchInterface(mustParsePatterns(t, "Queue"), store))
+	assert.False(t, matchInterface(mustParsePatterns(t, "re:^Store$"), store))
+
+	_, err := ParsePattern("re:(")
+	assert.Error(t, err)
+	_, err = ParsePattern("[")
+	assert.Error(t, err)
+}
+
+func TestWalkerExcludeInclude(t *testing.T) {
+	walk := func(exclude, include []Pattern) []string {
+		w := Walker{
+			BaseDir:   getFixturePath("buildtag"),
+			Recursive: true,
+			Filter:    regexp.MustCompile(".*"),
+			Exclude:   exclude,
+			Include:   include,
+		}
+		gv := NewGatheringVisitor()
+		_, err := w.WalkContext(context.Background(), gv)
+		require.NoError(t, err)
+
+		var names []string
+		for _, iface := range gv.Interfaces {
+			names = append(names, iface.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"IfaceWithBuildTagInComment", "IfaceWithBuildTagInFilename"}, walk(nil, nil))
+	assert.Equal(t, []string{"IfaceWithBuildTagInFilename"}, walk(mustParsePatterns(t, "comment"), nil))
+	assert.Equal(t, []string{"IfaceWithBuildTagInComment"}, walk(nil, mustParsePatterns(t, "comment")))
+	assert.Equal(t, []string{"IfaceWithBuildTagInComment"}, walk(mustParsePatterns(t, "re:InFilename$"), nil))
+}
@@ -0,0 +1,36 @@
+package mockery
+
+import (
+	"os"
+)
+
+// Prune deletes the files generated by mockery in the directories opts.Osp
+// writes to that aren't among files, which must be the result of a run of
+// Generate with the same options over every interface: ErrPartialRun is
+// returned when SrcPkg, Names, Include or Exclude leave some out. Files
+// without the header written by GeneratePrologueNote are never touched. The
+// orphaned files are returned; with opts.DryRun they are only listed.
+func Prune(opts Options, files []GeneratedFile) ([]string, error) {
+	if _, ok := opts.Osp.(pathProvider); !ok {
+		return nil, ErrNoOutputFiles
+	}
+
+	orphans, err := findOrphans(opts, files)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range orphans {
+		if opts.DryRun {
+			logf(opts.Logger, "Orphaned mock: %s\n", path)
+			continue
+		}
+
+		logf(opts.Logger, "Removing orphaned mock: %s\n", path)
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return orphans, nil
+}
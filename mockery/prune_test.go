@@ -0,0 +1,121 @@
+package mockery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	orphan := filepath.Join(dir, "Deleted.go")
+	require.NoError(t, ioutil.WriteFile(orphan, []byte(generatedHeader+" v0.0.0. DO NOT EDIT.\n\npackage mocks\n"), 0644))
+	handWritten := filepath.Join(dir, "helpers.go")
+	require.NoError(t, ioutil.WriteFile(handWritten, []byte("package mocks\n"), 0644))
+
+	opts := checkOptions(dir)
+	opts.DryRun = true
+	files, err := Generate(context.Background(), opts)
+	require.NoError(t, err)
+
+	orphans, err := Prune(opts, files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{orphan}, orphans)
+	assert.FileExists(t, orphan, "Dry runs don't delete anything.")
+
+	opts.DryRun = false
+	files, err = Generate(context.Background(), opts)
+	require.NoError(t, err)
+
+	orphans, err = Prune(opts, files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{orphan}, orphans)
+
+	_, err = os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, handWritten)
+	assert.FileExists(t, filepath.Join(dir, "Requester.go"))
+	assert.FileExists(t, filepath.Join(dir, "Requester2.go"))
+}
+
+func TestPruneConfiguredOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := LoadConfig(writeConfig(t, dir, `
+packages:
+  github.com/namely/mockery/mockery/fixtures:
+    interfaces:
+      Requester2:
+        output: requester2
+`))
+	require.NoError(t, err)
+
+	orphan := filepath.Join(dir, "requester2", "Deleted.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(orphan), 0755))
+	require.NoError(t, ioutil.WriteFile(orphan, []byte(generatedHeader+" v0.0.0. DO NOT EDIT.\n\npackage mocks\n"), 0644))
+
+	opts := checkOptions(filepath.Join(dir, "mocks"))
+	opts.Config = config
+	files, err := Generate(context.Background(), opts)
+	require.NoError(t, err)
+
+	orphans, err := Prune(opts, files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{orphan}, orphans, "Output directories of the configuration are pruned.")
+	assert.FileExists(t, filepath.Join(dir, "requester2", "Requester2.go"))
+}
+
+func TestPruneSkippedDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	header := []byte(generatedHeader + " v0.0.0. DO NOT EDIT.\n\npackage mocks\n")
+	orphan := filepath.Join(dir, "store", "Deleted.go")
+	golden := filepath.Join(dir, "testdata", "Golden.go")
+	vendored := filepath.Join(dir, "vendor", "example.com", "lib", "mocks", "Client.go")
+	for _, path := range []string{orphan, golden, vendored} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, header, 0644))
+	}
+
+	opts := checkOptions(dir)
+	files, err := Generate(context.Background(), opts)
+	require.NoError(t, err)
+
+	orphans, err := Prune(opts, files)
+	require.NoError(t, err)
+	assert.Equal(t, []string{orphan}, orphans, "Mocks in testdata and vendor directories are left alone.")
+	assert.FileExists(t, golden)
+	assert.FileExists(t, vendored)
+}
+
+func TestPrunePartialRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	other := filepath.Join(dir, "Requester2.go")
+	require.NoError(t, ioutil.WriteFile(other, []byte(generatedHeader+" v0.0.0. DO NOT EDIT.\n\npackage mocks\n"), 0644))
+
+	include, err := ParsePattern("fixtures.Requester")
+	require.NoError(t, err)
+	opts := checkOptions(dir)
+	opts.Include = []Pattern{include}
+	files, err := Generate(context.Background(), opts)
+	require.NoError(t, err)
+
+	_, err = Prune(opts, files)
+	assert.Equal(t, ErrPartialRun, err)
+	assert.FileExists(t, other, "Mocks of the interfaces left out aren't orphans.")
+}
@@ -0,0 +1,80 @@
+package mockery
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// Report tells what a run of Generate did for each interface, for the tools
+// wrapping mockery that need to know what was written.
+type Report struct {
+	Interfaces []ReportEntry `json:"interfaces"`
+	// The error the run failed with, including those of Interfaces
+	Error string `json:"error,omitempty"`
+}
+
+// ReportEntry is what a run did for a single interface.
+type ReportEntry struct {
+	// Qualified name, e.g. github.com/acme/svc/store.Store
+	Interface  string `json:"interface"`
+	SourceFile string `json:"source_file"`
+	// The file the mock was (or, with DryRun, would be) written to, empty
+	// unless it is written to a file
+	Output   string `json:"output,omitempty"`
+	MockName string `json:"mock_name,omitempty"`
+	// The file didn't exist or had a different content, see
+	// GeneratedFile.Changed
+	Changed bool `json:"changed"`
+	// The mock wasn't generated thanks to the cache, see
+	// GeneratedFile.Cached
+	Cached bool   `json:"cached"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WriteJSON writes the report as indented JSON. Interfaces is always a
+// list, empty when nothing was visited.
+func (r *Report) WriteJSON(w io.Writer) error {
+	report := *r
+	if report.Interfaces == nil {
+		report.Interfaces = []ReportEntry{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// add records file, as far as it got, for each of its interfaces. It does
+// nothing on a nil report.
+func (r *Report) add(visitor *GeneratorVisitor, file GeneratedFile, err error) {
+	if r == nil {
+		return
+	}
+	for _, iface := range file.Interfaces {
+		entry := ReportEntry{
+			Interface:  iface.QualifiedName + "." + iface.Name,
+			SourceFile: iface.FileName,
+			Output:     file.Path,
+			Changed:    file.Changed,
+			Cached:     file.Cached,
+		}
+		if name, nameErr := visitor.mockName(iface); nameErr == nil {
+			entry.MockName = name
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		r.Interfaces = append(r.Interfaces, entry)
+	}
+}
+
+// changes tells whether writing content to path changes the file.
+func changes(path string, content []byte) bool {
+	if path == "" {
+		return false
+	}
+	existing, err := ioutil.ReadFile(path)
+	return err != nil || !bytes.Equal(existing, content)
+}
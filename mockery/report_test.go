@@ -0,0 +1,151 @@
+package mockery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	generate := func() *Report {
+		report := &Report{}
+		_, err := Generate(context.Background(), Options{
+			Dir:       fixturePath,
+			Filter:    regexp.MustCompile("^(StructLiteralUnexported|Requester2)$"),
+			BuildTags: []string{"unmockable"},
+			KeepGoing: true,
+			MockName:  "Mock{{.InterfaceName}}",
+			CacheDir:  filepath.Join(dir, "cache"),
+			Osp:       &FileOutputStreamProvider{BaseDir: dir},
+			Report:    report,
+		})
+		require.Error(t, err)
+		assert.Equal(t, err.Error(), report.Error)
+		return report
+	}
+
+	report := generate()
+	require.Len(t, report.Interfaces, 2)
+	byName := make(map[string]ReportEntry)
+	for _, entry := range report.Interfaces {
+		byName[entry.Interface] = entry
+	}
+
+	entry := byName["github.com/namely/mockery/mockery/fixtures.Requester2"]
+	assert.Equal(t, getFixturePath("requester2.go"), entry.SourceFile)
+	assert.Equal(t, filepath.Join(dir, "Requester2.go"), entry.Output)
+	assert.Equal(t, "MockRequester2", entry.MockName)
+	assert.True(t, entry.Changed)
+	assert.False(t, entry.Cached)
+	assert.Empty(t, entry.Error)
+
+	failed := byName["github.com/namely/mockery/mockery/fixtures.StructLiteralUnexported"]
+	assert.Equal(t, filepath.Join(dir, "StructLiteralUnexported.go"), failed.Output)
+	assert.False(t, failed.Changed)
+	assert.Contains(t, failed.Error, "StructLiteralUnexported")
+
+	report = generate()
+	for _, entry := range report.Interfaces {
+		if entry.Error == "" {
+			assert.False(t, entry.Changed, "The mock on disk is up to date.")
+			assert.True(t, entry.Cached)
+		}
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "Requester2.go")))
+	report = generate()
+	for _, entry := range report.Interfaces {
+		if entry.Error == "" {
+			assert.True(t, entry.Changed)
+			assert.True(t, entry.Cached, "The mock is copied from the cache.")
+		}
+	}
+}
+
+func TestGenerateReportWithoutCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	generate := func() ReportEntry {
+		report := &Report{}
+		_, err := Generate(context.Background(), Options{
+			Dir:      fixturePath,
+			Filter:   regexp.MustCompile("^Requester2$"),
+			LimitOne: true,
+			Osp:      &FileOutputStreamProvider{BaseDir: dir},
+			Report:   report,
+		})
+		require.NoError(t, err)
+		require.Len(t, report.Interfaces, 1)
+		return report.Interfaces[0]
+	}
+
+	assert.True(t, generate().Changed)
+	entry := generate()
+	assert.False(t, entry.Changed, "The mock is written again with the same content.")
+	assert.False(t, entry.Cached)
+}
+
+func TestGenerateReportPackageFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	report := &Report{}
+	_, err = Generate(context.Background(), Options{
+		Dir:          getFixturePath("testdata", "collision", "c"),
+		PackageFiles: true,
+		Osp:          &FileOutputStreamProvider{BaseDir: dir},
+		Report:       report,
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Interfaces, 1)
+	assert.Equal(t, collisionPkg+"/c.Store", report.Interfaces[0].Interface)
+	assert.Equal(t, filepath.Join(dir, "c_mocks.go"), report.Interfaces[0].Output)
+	assert.Equal(t, "Store", report.Interfaces[0].MockName)
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	report := &Report{Interfaces: []ReportEntry{{
+		Interface:  "github.com/acme/svc/store.Store",
+		SourceFile: "store/store.go",
+		Output:     "mocks/Store.go",
+		MockName:   "Store",
+		Changed:    true,
+	}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buf))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, map[string]interface{}{
+		"interfaces": []interface{}{map[string]interface{}{
+			"interface":   "github.com/acme/svc/store.Store",
+			"source_file": "store/store.go",
+			"output":      "mocks/Store.go",
+			"mock_name":   "Store",
+			"changed":     true,
+			"cached":      false,
+		}},
+	}, decoded)
+}
+
+func TestReportWriteJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, (&Report{Error: "unable to find Store in ."}).WriteJSON(&buf))
+	assert.JSONEq(t, `{"interfaces": [], "error": "unable to find Store in ."}`, buf.String())
+}
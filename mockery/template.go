@@ -0,0 +1,105 @@
+package mockery
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NameData is what the templates naming mock files and mock types, such as
+// "{{.InterfaceName | snakecase}}_mock.go" or
+// "Mock{{.InterfaceName | firstUpper}}", are executed with.
+type NameData struct {
+	InterfaceName string
+	// Name of the package declaring the interface
+	PackageName string
+	// Import path of the package declaring the interface
+	PackagePath string
+	// Base name of the file declaring the interface, e.g. store.go
+	SourceFile string
+}
+
+func nameData(iface *Interface) NameData {
+	data := NameData{
+		InterfaceName: iface.Name,
+		PackagePath:   iface.QualifiedName,
+		SourceFile:    filepath.Base(iface.FileName),
+	}
+	if iface.Pkg != nil {
+		data.PackageName = iface.Pkg.Name()
+	}
+	return data
+}
+
+// nameFuncs are the functions available to name templates.
+var nameFuncs = template.FuncMap{
+	"snakecase":  snakeCase,
+	"firstUpper": firstUpper,
+	"firstLower": firstLower,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+}
+
+// nameTemplates caches parsed templates by text, as they are executed for
+// every interface, possibly concurrently.
+var nameTemplates sync.Map
+
+// isNameTemplate tells a template from a plain name.
+func isNameTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// CheckNameTemplate reports whether text is a valid name template.
+func CheckNameTemplate(text string) error {
+	_, err := parseNameTemplate(text)
+	return err
+}
+
+func parseNameTemplate(text string) (*template.Template, error) {
+	if tmpl, ok := nameTemplates.Load(text); ok {
+		return tmpl.(*template.Template), nil
+	}
+
+	tmpl, err := template.New("name").Funcs(nameFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template %q: %s", text, err)
+	}
+	nameTemplates.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// executeNameTemplate names the mock of iface, or its file, with the template
+// text.
+func executeNameTemplate(text string, iface *Interface) (string, error) {
+	tmpl, err := parseNameTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nameData(iface)); err != nil {
+		return "", fmt.Errorf("unable to execute name template %q: %s", text, err)
+	}
+	return buf.String(), nil
+}
+
+func firstUpper(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+func firstLower(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
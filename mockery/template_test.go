@@ -0,0 +1,29 @@
+package mockery
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteNameTemplate(t *testing.T) {
+	iface := &Interface{Name: "requesterUnexported", QualifiedName: "example.com/svc", FileName: "/src/svc/requester.go", Pkg: types.NewPackage("example.com/svc", "svc")}
+
+	for text, expected := range map[string]string{
+		"Mock{{.InterfaceName | firstUpper}}":        "MockRequesterUnexported",
+		"{{.InterfaceName | snakecase}}_mock.go":     "requester_unexported_mock.go",
+		"{{.PackageName | upper}}{{.InterfaceName}}": "SVCrequesterUnexported",
+		"{{.SourceFile}}":                            "requester.go",
+		"{{.PackagePath | lower | firstLower}}":      "example.com/svc",
+	} {
+		name, err := executeNameTemplate(text, iface)
+		assert.NoError(t, err, text)
+		assert.Equal(t, expected, name, text)
+	}
+
+	assert.Error(t, CheckNameTemplate("{{.InterfaceName"))
+	assert.Error(t, CheckNameTemplate("{{.InterfaceName | unknown}}"))
+	_, err := executeNameTemplate("{{.Interface}}", iface)
+	assert.Error(t, err)
+}
@@ -1,62 +1,486 @@
 package mockery
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"go/token"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
 	"strings"
+	"sync"
 )
 
 type Walker struct {
-	BaseDir   string
+	BaseDir string
+	// Directories to walk instead of BaseDir when not empty, all loaded at
+	// once. Exclude and Include patterns are relative to the one a
+	// directory is under.
+	Dirs      []string
 	Recursive bool
 	Filter    *regexp.Regexp
 	LimitOne  bool
 	BuildTags []string
+	// Import path of a package to load instead of walking BaseDir, which
+	// may be outside the current module, e.g. net/http
+	SrcPkg string
+	// Report errors and carry on with the remaining files and interfaces
+	// instead of stopping at the first one. The run still fails at the end.
+	KeepGoing bool
+	// Directories and interfaces matching any of Exclude are skipped, along
+	// with testdata and vendor directories. When Include isn't empty, only the
+	// interfaces in a directory or with a name matching one of its patterns
+	// are visited.
+	Exclude []Pattern
+	Include []Pattern
+	// Number of interfaces visited at once by a ConcurrentVisitor,
+	// GOMAXPROCS when zero. Interfaces are visited one at a time with
+	// LimitOne.
+	Jobs int
+	// Also visit the interfaces declared in _test.go files, including
+	// external _test packages. Their mocks are _test.go files of the same
+	// package.
+	IncludeTests bool
+	// When not empty, only the interfaces with one of these names are
+	// visited. Each name must match a single interface: the walk fails,
+	// before visiting anything, with an *AmbiguousNameError for each name
+	// matching several and an error for each name matching none.
+	Names []InterfaceName
+	// Matched against the names of interfaces instead of Names when the
+	// qualifier of its single name names none of the loaded packages, for
+	// names that may be regular expressions as well, such as Req.ester
+	NamesFallback *regexp.Regexp
+	// Loads the packages, kept between walks so that only the packages
+	// given to Parser.Invalidate are loaded again. A new one is used for
+	// each walk when nil.
+	Parser *Parser
 }
 
 type WalkerVisitor interface {
 	VisitWalk(*Interface) error
 }
 
-func (this *Walker) Walk(visitor WalkerVisitor) (generated bool) {
-	parser := NewParser(this.BuildTags)
-	this.doWalk(parser, this.BaseDir, visitor)
+// ConcurrentVisitor is a WalkerVisitor that can visit several interfaces at
+// once. Walker calls PrepareWalk from several goroutines, and then calls the
+// done functions it returns one at a time, in the order of the interfaces,
+// whether or not PrepareWalk failed. Anything that must happen in order,
+// such as logging, belongs in done.
+type ConcurrentVisitor interface {
+	WalkerVisitor
+	PrepareWalk(*Interface) (done func(), err error)
+}
+
+// PlanningVisitor is a WalkerVisitor that is given every interface to visit
+// before any of them is, to check or adjust what it will do with them. The
+// walk stops, without visiting anything, when PlanWalk fails. It isn't
+// called with LimitOne.
+type PlanningVisitor interface {
+	WalkerVisitor
+	PlanWalk([]*Interface) error
+}
+
+// Errors holds every error of a run that kept going past failures.
+type Errors []error
 
-	err := parser.Load()
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Walk is WalkContext without a context, which prints the error and exits
+// the process instead of returning it.
+func (this *Walker) Walk(visitor WalkerVisitor) (generated bool) {
+	generated, err := this.WalkContext(context.Background(), visitor)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error walking: %v\n", err)
 		os.Exit(1)
 	}
+	return generated
+}
 
-	for _, iface := range parser.Interfaces() {
-		if !this.Filter.MatchString(iface.Name) {
-			continue
+// WalkContext loads the packages of the directories under BaseDir, or
+// SrcPkg, all at once and visits the interfaces matching Filter and Names.
+// The directories are loaded as part of the module of the first one, and
+// SrcPkg as a dependency of the module of the current directory. It stops at
+// the first error unless KeepGoing is set, in which case all of them are
+// returned as Errors. Cancelling ctx aborts the walk, including any running
+// package loading.
+func (this *Walker) WalkContext(ctx context.Context, visitor WalkerVisitor) (generated bool, err error) {
+	var errs Errors
+
+	parser := this.Parser
+	if parser == nil {
+		parser = NewParser(this.BuildTags)
+	}
+	parser.conf.Context = ctx
+	parser.conf.Tests = this.IncludeTests
+
+	if this.SrcPkg != "" {
+		parser.ParsePackage(this.SrcPkg)
+	} else {
+		// The go command resolves the packages in the module of the
+		// directory it runs in.
+		parser.conf.Dir = this.roots()[0]
+		for _, dir := range this.roots() {
+			if err := this.doWalk(ctx, parser, dir, &errs); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	// Labelled so that CPU profiles tell loading apart from generating.
+	defer pprof.SetGoroutineLabels(ctx)
+	var loadErr error
+	pprof.Do(ctx, pprof.Labels("mockery", "load"), func(context.Context) {
+		loadErr = parser.Load()
+	})
+	if loadErr != nil {
+		loadErrs, ok := loadErr.(Errors)
+		if !ok {
+			return false, fmt.Errorf("error walking: %w", loadErr)
+		}
+		for _, err := range loadErrs {
+			if err := this.fail(&errs, fmt.Errorf("error loading package: %w", err)); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	ifaces, err := this.interfaces(parser, &errs)
+	if err != nil {
+		return false, err
+	}
+
+	if planner, ok := visitor.(PlanningVisitor); ok && !this.LimitOne {
+		if err := planner.PlanWalk(ifaces); err != nil {
+			return false, err
+		}
+	}
+
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels("mockery", "generate")))
+	if concurrent, ok := visitor.(ConcurrentVisitor); ok && !this.LimitOne && this.jobs() > 1 {
+		generated, err = this.visitConcurrently(ctx, concurrent, ifaces, &errs)
+		if err != nil {
+			return generated, err
+		}
+		return generated, errs.errorOrNil()
+	}
+
+	for _, iface := range ifaces {
+		if err := ctx.Err(); err != nil {
+			return generated, err
 		}
 		err := visitor.VisitWalk(iface)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error walking %s: %s\n", iface.Name, err)
-			os.Exit(1)
+			if err := this.fail(&errs, fmt.Errorf("error walking %s: %w", iface.Name, err)); err != nil {
+				return generated, err
+			}
+			continue
 		}
 		generated = true
 		if this.LimitOne {
+			break
+		}
+	}
+
+	return generated, errs.errorOrNil()
+}
+
+// interfaces returns the interfaces of parser to visit.
+func (this *Walker) interfaces(parser *Parser, errs *Errors) ([]*Interface, error) {
+	var candidates []*Interface
+	for _, iface := range parser.Interfaces() {
+		if this.Filter.MatchString(iface.Name) && !iface.Directives.Skip && this.selected(iface) {
+			candidates = append(candidates, iface)
+		}
+	}
+	if len(this.Names) == 0 {
+		return candidates, nil
+	}
+	if this.NamesFallback != nil && !this.qualifierFound(parser) {
+		var ifaces []*Interface
+		for _, iface := range candidates {
+			if this.NamesFallback.MatchString(iface.Name) {
+				ifaces = append(ifaces, iface)
+			}
+		}
+		return ifaces, nil
+	}
+
+	var nameErrs Errors
+	named := make(map[*Interface]bool)
+	for _, name := range this.Names {
+		var matches []*Interface
+		for _, iface := range candidates {
+			if name.matches(iface) {
+				matches = append(matches, iface)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			nameErrs = append(nameErrs, fmt.Errorf("unable to find %s in %s", name, this.where()))
+		case 1:
+			named[matches[0]] = true
+		default:
+			nameErrs = append(nameErrs, &AmbiguousNameError{Name: name, Candidates: matches})
+		}
+	}
+	if len(nameErrs) > 0 {
+		if !this.KeepGoing {
+			if len(nameErrs) == 1 {
+				return nil, nameErrs[0]
+			}
+			return nil, nameErrs
+		}
+		*errs = append(*errs, nameErrs...)
+	}
+
+	var ifaces []*Interface
+	for _, iface := range candidates {
+		if named[iface] {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	return ifaces, nil
+}
+
+func (this *Walker) jobs() int {
+	if this.Jobs > 0 {
+		return this.Jobs
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// visitConcurrently visits ifaces with a pool of Jobs workers. Results are
+// handled in the order of ifaces, as soon as all of the previous ones are
+// available, so that the outcome doesn't depend on scheduling. Unless
+// KeepGoing is set, no interface is started once one failed, but those
+// other workers are already visiting are still written.
+func (this *Walker) visitConcurrently(ctx context.Context, visitor ConcurrentVisitor, ifaces []*Interface, errs *Errors) (generated bool, err error) {
+	type result struct {
+		done  func()
+		err   error
+		ready chan struct{}
+	}
+
+	results := make([]result, len(ifaces))
+	for i := range results {
+		results[i].ready = make(chan struct{})
+	}
+
+	workCtx, cancel := context.WithCancel(ctx)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for j := 0; j < this.jobs(); j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := workCtx.Err(); err != nil {
+					results[i].err = err
+				} else {
+					results[i].done, results[i].err = visitor.PrepareWalk(ifaces[i])
+				}
+				// Interfaces are handed out in order, so the ones
+				// before have all been started already.
+				if results[i].err != nil && !this.KeepGoing {
+					cancel()
+				}
+				close(results[i].ready)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(next)
+		for i := range ifaces {
+			select {
+			case next <- i:
+			case <-workCtx.Done():
+				for ; i < len(ifaces); i++ {
+					results[i].err = workCtx.Err()
+					close(results[i].ready)
+				}
+				return
+			}
+		}
+	}()
+
+	for i, iface := range ifaces {
+		<-results[i].ready
+		if err := ctx.Err(); err != nil {
+			return generated, err
+		}
+
+		r := results[i]
+		if r.done != nil {
+			r.done()
+		}
+		if r.err != nil {
+			if err := this.fail(errs, fmt.Errorf("error walking %s: %w", iface.Name, r.err)); err != nil {
+				return generated, err
+			}
+			continue
+		}
+		generated = true
+	}
+
+	return generated, nil
+}
+
+// qualifierFound tells whether the qualifier of the first of Names names
+// the package of an interface of parser.
+func (this *Walker) qualifierFound(parser *Parser) bool {
+	for _, iface := range parser.Interfaces() {
+		if this.Names[0].qualifies(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+// fail returns err to stop the walk, or records it in errs when KeepGoing is
+// set.
+func (this *Walker) fail(errs *Errors, err error) error {
+	if !this.KeepGoing {
+		return err
+	}
+	*errs = append(*errs, err)
+	return nil
+}
+
+// selected tells whether iface passes the Exclude and Include patterns.
+func (this *Walker) selected(iface *Interface) bool {
+	if matchInterface(this.Exclude, iface) {
+		return false
+	}
+	if len(this.Include) == 0 {
+		return true
+	}
+	return matchInterface(this.Include, iface) || matchDir(this.Include, this.relDir(filepath.Dir(iface.FileName)))
+}
+
+// roots returns the directories to walk.
+func (this *Walker) roots() []string {
+	if len(this.Dirs) > 0 {
+		return this.Dirs
+	}
+	return []string{this.BaseDir}
+}
+
+// where describes what is walked, for messages.
+func (this *Walker) where() string {
+	if this.SrcPkg != "" {
+		return "package " + this.SrcPkg
+	}
+	return strings.Join(this.roots(), ", ")
+}
+
+// relDir returns the path of dir relative to the walked directory it is
+// under, or else to the first one, with forward slashes.
+func (this *Walker) relDir(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+
+	var rels []string
+	for _, root := range this.roots() {
+		base, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+		rels = append(rels, rel)
+	}
+	if len(rels) == 0 {
+		return filepath.ToSlash(dir)
+	}
+	return filepath.ToSlash(rels[0])
+}
+
+// skipDir tells whether the walk leaves out the directory at path.
+func (this *Walker) skipDir(path string) bool {
+	return skippedDir(filepath.Base(path)) || matchDir(this.Exclude, this.relDir(path))
+}
+
+// skippedDir tells whether directories with the given name are always left
+// out, as the go command does for ./... patterns.
+func skippedDir(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor"
+}
+
+// watchedDirs returns the directories the walk reads, as absolute paths.
+func (this *Walker) watchedDirs() []string {
+	var dirs []string
+	var walk func(dir string)
+	walk = func(dir string) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
 			return
 		}
+		dirs = append(dirs, abs)
+		if !this.Recursive {
+			return
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, file := range files {
+			if path := filepath.Join(dir, file.Name()); file.IsDir() && !this.skipDir(path) {
+				walk(path)
+			}
+		}
 	}
 
-	return
+	for _, root := range this.roots() {
+		walk(root)
+	}
+	return dirs
 }
 
-func (this *Walker) doWalk(p *Parser, dir string, visitor WalkerVisitor) (generated bool) {
+func (this *Walker) doWalk(ctx context.Context, p *Parser, dir string, errs *Errors) error {
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
-		return
+		return nil
 	}
 
 	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if strings.HasPrefix(file.Name(), ".") || strings.HasPrefix(file.Name(), "_") {
 			continue
 		}
@@ -64,73 +488,347 @@ func (this *Walker) doWalk(p *Parser, dir string, visitor WalkerVisitor) (genera
 		path := filepath.Join(dir, file.Name())
 
 		if file.IsDir() {
+			if this.skipDir(path) {
+				continue
+			}
 			if this.Recursive {
-				generated = this.doWalk(p, path, visitor) || generated
-				if generated && this.LimitOne {
-					return
+				if err := this.doWalk(ctx, p, path, errs); err != nil {
+					return err
 				}
 			}
 			continue
 		}
 
-		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+		if !strings.HasSuffix(path, ".go") || (strings.HasSuffix(path, "_test.go") && !this.IncludeTests) {
 			continue
 		}
 
 		err = p.Parse(path)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error parsing file: ", err)
+			if err := this.fail(errs, fmt.Errorf("error parsing file: %w", err)); err != nil {
+				return err
+			}
 			continue
 		}
 	}
 
-	return
+	return nil
 }
 
 type GeneratorVisitor struct {
 	InPackage bool
 	Note      string
 	Osp       OutputStreamProvider
-	// The name of the output package, if InPackage is false (defaults to "mocks")
+	// The name of the output package, if InPackage is false (defaults to
+	// "mocks"), or a template deriving it from the interface's package, see
+	// NameData
 	PackageName string
+	// Render type aliases as the type they stand for instead of by name
+	ExpandAliases bool
+	// Template naming the mock types, see NameData, unless they have a
+	// //mockery:name directive
+	MockName string
+	// Prepended to the names of the mock types, such as to tell apart the
+	// mocks of same-named interfaces, see Disambiguate
+	NamePrefix string
+	// Directory caching generated mocks by fingerprint. When set, mocks whose
+	// file already has the same fingerprint aren't generated nor written.
+	CacheDir string
+	// Receives progress messages, nothing is logged when nil
+	Logger Logger
 }
 
 func (this *GeneratorVisitor) VisitWalk(iface *Interface) error {
+	done, err := this.PrepareWalk(iface)
+	done()
+	return err
+}
+
+// PrepareWalk generates and writes the mock of iface like VisitWalk, and can
+// be called concurrently. Messages are held back until done is called.
+func (this *GeneratorVisitor) PrepareWalk(iface *Interface) (done func(), err error) {
+	visitor, logs := interfaceSettings(iface).apply(this).buffered()
+	return logs.flush, visitor.visit(iface)
+}
+
+// PlanWalk checks that no two mocks would be written to the same file, or
+// get the same name in the same directory.
+func (this *GeneratorVisitor) PlanWalk(ifaces []*Interface) error {
+	mocks, err := planMocks(ifaces, func(iface *Interface) *GeneratorVisitor {
+		return interfaceSettings(iface).apply(this)
+	}, false)
+	if err != nil {
+		return err
+	}
+	if collisions := findCollisions(mocks, false); len(collisions) > 0 {
+		return collisionErrors(collisions)
+	}
+	return nil
+}
+
+func (this *GeneratorVisitor) visit(iface *Interface) error {
+	path, err := this.path(iface)
+	if err != nil {
+		return err
+	}
+
+	content, unchanged, _, err := this.render(iface, path)
+	if err != nil || unchanged {
+		return err
+	}
+
+	return this.write(iface, path, content)
+}
+
+// buffered returns a copy of the visitor logging to a buffer, flushed to
+// the visitor's Logger on demand.
+func (this *GeneratorVisitor) buffered() (*GeneratorVisitor, *logBuffer) {
+	logs := &logBuffer{logger: this.Logger}
+	visitor := *this
+	visitor.Logger = logs
+	return &visitor, logs
+}
+
+// logBuffer holds the messages of a visit until they can be logged in
+// order.
+type logBuffer struct {
+	logger Logger
+	msgs   []string
+}
+
+func (b *logBuffer) Printf(format string, args ...interface{}) {
+	b.msgs = append(b.msgs, fmt.Sprintf(format, args...))
+}
+
+func (b *logBuffer) flush() {
+	for _, msg := range b.msgs {
+		logf(b.logger, "%s", msg)
+	}
+}
+
+// render returns the mock for iface, from the cache when possible. When the
+// mock at path already has the same fingerprint, its content is returned
+// and unchanged is set. cached is set whenever the mock wasn't generated,
+// either way.
+func (this *GeneratorVisitor) render(iface *Interface, path string) (content []byte, unchanged, cached bool, err error) {
+	return this.renderCached(iface.Name, path, this.fingerprint(iface), func(fingerprint string) ([]byte, error) {
+		return this.generate([]*Interface{iface}, fingerprint)
+	})
+}
+
+// renderPackage returns the file holding the mocks of ifaces, all from the
+// same package, like render.
+func (this *GeneratorVisitor) renderPackage(ifaces []*Interface, path string) (content []byte, unchanged, cached bool, err error) {
+	return this.renderCached(interfaceNames(ifaces), path, this.packageFingerprint(ifaces), func(fingerprint string) ([]byte, error) {
+		return this.generate(ifaces, fingerprint)
+	})
+}
+
+func (this *GeneratorVisitor) renderCached(name, path, fingerprint string, generate func(fingerprint string) ([]byte, error)) (content []byte, unchanged, cached bool, err error) {
+	if this.CacheDir == "" {
+		content, err = generate(fingerprint)
+		return content, false, false, err
+	}
+
+	if path != "" {
+		if existing, err := ioutil.ReadFile(path); err == nil && readFingerprint(existing) == fingerprint {
+			this.logf("Skipping unchanged mock for: %s in file: %s\n", name, path)
+			return existing, true, true, nil
+		}
+	}
+
+	c := cache{dir: this.CacheDir}
+	if content, ok := c.get(fingerprint); ok {
+		return content, false, true, nil
+	}
+
+	content, err = generate(fingerprint)
+	if err != nil {
+		return nil, false, false, err
+	}
+	return content, false, false, c.put(fingerprint, content)
+}
+
+// generate renders the mocks for ifaces, in a single file, without writing
+// it anywhere.
+func (this *GeneratorVisitor) generate(ifaces []*Interface, fingerprint string) (content []byte, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("Unable to generated mock for '%s': %s\n", iface.Name, r)
-			return
+			err = fmt.Errorf("unable to generate mock for '%s': %v\n%s", interfaceNames(ifaces), r, debug.Stack())
 		}
 	}()
 
-	var out io.Writer
 	var pkg string
 
 	if this.InPackage {
-		pkg = filepath.Dir(iface.FileName)
-	} else {
-		pkg = this.PackageName
+		pkg = filepath.Dir(ifaces[0].FileName)
+	} else if pkg, err = this.packageName(ifaces[0]); err != nil {
+		return nil, err
 	}
 
-	out, err, closer := this.Osp.GetWriter(iface)
-	if err != nil {
-		fmt.Printf("Unable to get writer for %s: %s", iface.Name, err)
-		os.Exit(1)
+	gen := NewPackageGenerator(ifaces, pkg, this.InPackage)
+	gen.ExpandAliases = this.ExpandAliases
+	gen.Fingerprint = fingerprint
+	if gen.MockNames, err = this.mockNames(ifaces); err != nil {
+		return nil, err
 	}
-	defer closer() //nolint:errcheck
-
-	gen := NewGenerator(iface, pkg, this.InPackage)
 	gen.GeneratePrologueNote(this.Note)
 	gen.GeneratePrologue(pkg)
 
 	err = gen.Generate()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = gen.Write(out)
+	var buf bytes.Buffer
+	err = gen.Write(&buf)
 	if err != nil {
-		return err
+		// The source goes with the error, rather than in the progress
+		// messages, to be reported along with it.
+		line := "--------------------------------------------------------------------------------------------"
+		return nil, fmt.Errorf("%w\nBetween the lines is the file (mock.go) mockery generated in-memory but detected as invalid:\n%s\n%s\n%s", err, line, gen.buf.String(), line)
 	}
-	return nil
+
+	return buf.Bytes(), nil
+}
+
+// packageName returns the name of the package of the mock of iface when it
+// goes in a package of its own.
+func (this *GeneratorVisitor) packageName(iface *Interface) (string, error) {
+	if !isNameTemplate(this.PackageName) {
+		return this.PackageName, nil
+	}
+
+	name, err := executeNameTemplate(this.PackageName, iface)
+	if err != nil {
+		return "", err
+	}
+	if !token.IsIdentifier(name) {
+		return "", fmt.Errorf("invalid package name %q for %s", name, iface.Name)
+	}
+	return name, nil
+}
+
+// mockNames names the mocks of ifaces, see mockName.
+func (this *GeneratorVisitor) mockNames(ifaces []*Interface) (map[*Interface]string, error) {
+	names := make(map[*Interface]string)
+	for _, iface := range ifaces {
+		name, err := this.mockName(iface)
+		if err != nil {
+			return nil, err
+		}
+		names[iface] = name
+	}
+	return names, nil
+}
+
+// mockName names the mock of iface after its //mockery:name directive, the
+// MockName template or the interface, with NamePrefix.
+func (this *GeneratorVisitor) mockName(iface *Interface) (string, error) {
+	name := iface.Directives.Name
+	if name == "" && this.MockName != "" {
+		var err error
+		if name, err = executeNameTemplate(this.MockName, iface); err != nil {
+			return "", err
+		}
+		if !token.IsIdentifier(name) {
+			return "", fmt.Errorf("invalid mock name %q for %s", name, iface.Name)
+		}
+	}
+	if name == "" {
+		name = defaultMockName(iface.Name, this.InPackage)
+	}
+	return this.NamePrefix + name, nil
+}
+
+// interfaceNames lists the names of ifaces for messages.
+func interfaceNames(ifaces []*Interface) string {
+	names := make([]string, len(ifaces))
+	for i, iface := range ifaces {
+		names[i] = iface.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// path returns the file the mock for iface is written to, or an empty
+// string when Osp doesn't write to files.
+func (this *GeneratorVisitor) path(iface *Interface) (string, error) {
+	paths, ok := this.Osp.(pathProvider)
+	if !ok {
+		return "", nil
+	}
+
+	path, err := paths.Path(iface)
+	if err != nil {
+		return "", fmt.Errorf("unable to get writer for %s: %s", iface.Name, err)
+	}
+	return path, nil
+}
+
+// packagePath returns the file the mocks of the package declaring iface are
+// written to when they share a file, or an empty string when Osp doesn't
+// write to files.
+func (this *GeneratorVisitor) packagePath(iface *Interface) (string, error) {
+	paths, ok := this.Osp.(packageOutputStreamProvider)
+	if !ok {
+		return "", nil
+	}
+
+	path, err := paths.PackagePath(iface)
+	if err != nil {
+		return "", fmt.Errorf("unable to get writer for %s: %s", iface.Pkg.Name(), err)
+	}
+	return path, nil
+}
+
+// write outputs a generated mock through Osp. path is only used for
+// logging.
+func (this *GeneratorVisitor) write(iface *Interface, path string, content []byte) error {
+	out, err, closer := this.Osp.GetWriter(iface)
+	if err != nil {
+		return fmt.Errorf("unable to get writer for %s: %s", iface.Name, err)
+	}
+	defer closer() //nolint:errcheck
+
+	if path != "" {
+		this.logf("Generating mock for: %s in file: %s\n", iface.Name, path)
+	}
+
+	_, err = out.Write(content)
+	return err
+}
+
+// writePackage outputs the file holding the mocks of ifaces through Osp.
+// path is only used for logging.
+func (this *GeneratorVisitor) writePackage(ifaces []*Interface, path string, content []byte) error {
+	var out io.Writer
+	var err error
+	var closer Cleanup
+	if packages, ok := this.Osp.(packageOutputStreamProvider); ok {
+		out, err, closer = packages.GetPackageWriter(ifaces[0])
+	} else {
+		out, err, closer = this.Osp.GetWriter(ifaces[0])
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get writer for %s: %s", ifaces[0].Pkg.Name(), err)
+	}
+	defer closer() //nolint:errcheck
+
+	if path != "" {
+		this.logf("Generating mocks for: %s in file: %s\n", interfaceNames(ifaces), path)
+	}
+
+	_, err = out.Write(content)
+	return err
+}
+
+func (this *GeneratorVisitor) logf(format string, args ...interface{}) {
+	logf(this.Logger, format, args...)
+}
+
+// sameFile tells whether mocks generated by this and o can share a file.
+func (this *GeneratorVisitor) sameFile(o *GeneratorVisitor) bool {
+	return this.InPackage == o.InPackage && this.PackageName == o.PackageName &&
+		this.Note == o.Note && this.ExpandAliases == o.ExpandAliases && this.MockName == o.MockName &&
+		this.NamePrefix == o.NamePrefix
 }
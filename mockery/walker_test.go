@@ -1,11 +1,15 @@
 package mockery
 
 import (
+	"context"
+	"errors"
 	"os"
 	"regexp"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type GatheringVisitor struct {
@@ -72,3 +76,107 @@ func TestWalkerRegexp(t *testing.T) {
 	assert.Equal(t, getFixturePath("async.go"), first.FileName)
 	assert.Equal(t, "github.com/namely/mockery/mockery/fixtures", first.QualifiedName)
 }
+
+// failingVisitor fails to visit the interfaces named in fail, and records
+// the others.
+type failingVisitor struct {
+	fail    map[string]bool
+	visited []string
+}
+
+func (this *failingVisitor) VisitWalk(iface *Interface) error {
+	if this.fail[iface.Name] {
+		return errors.New("unable to visit")
+	}
+	this.visited = append(this.visited, iface.Name)
+	return nil
+}
+
+func TestWalkerKeepGoing(t *testing.T) {
+	w := Walker{
+		BaseDir: fixturePath,
+		Filter:  regexp.MustCompile(`^Requester\d$`),
+	}
+
+	visitor := &failingVisitor{fail: map[string]bool{"Requester3": true}}
+	generated, err := w.WalkContext(context.Background(), visitor)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error walking Requester3: unable to visit")
+	assert.True(t, generated)
+	assert.Equal(t, []string{"Requester2"}, visitor.visited, "The walk stops at the first error.")
+
+	w.KeepGoing = true
+	visitor = &failingVisitor{fail: map[string]bool{"Requester3": true}}
+	generated, err = w.WalkContext(context.Background(), visitor)
+	require.Error(t, err, "The walk fails when an interface failed.")
+	if assert.IsType(t, Errors{}, err) {
+		assert.Len(t, err.(Errors), 1)
+	}
+	assert.True(t, generated)
+	assert.Equal(t, []string{"Requester2", "Requester4"}, visitor.visited, "The walk goes on past the error.")
+}
+
+// concurrentFailingVisitor is a failingVisitor visiting interfaces
+// concurrently. The failing interface waits for blocking to be started, and
+// blocking waits for the done function of the failing one, so that the
+// other workers are busy when it fails.
+type concurrentFailingVisitor struct {
+	failingVisitor
+	blocking string
+	started  chan struct{}
+	release  chan struct{}
+
+	mu       sync.Mutex
+	prepared []string
+}
+
+func newConcurrentFailingVisitor(fail, blocking string) *concurrentFailingVisitor {
+	return &concurrentFailingVisitor{
+		failingVisitor: failingVisitor{fail: map[string]bool{fail: true}},
+		blocking:       blocking,
+		started:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+}
+
+func (this *concurrentFailingVisitor) PrepareWalk(iface *Interface) (func(), error) {
+	this.mu.Lock()
+	this.prepared = append(this.prepared, iface.Name)
+	this.mu.Unlock()
+
+	if this.fail[iface.Name] {
+		<-this.started
+		return func() { close(this.release) }, errors.New("unable to visit")
+	}
+	if iface.Name == this.blocking {
+		close(this.started)
+		<-this.release
+	}
+	return func() { this.visited = append(this.visited, iface.Name) }, nil
+}
+
+func TestWalkerKeepGoingConcurrently(t *testing.T) {
+	w := Walker{
+		BaseDir: fixturePath,
+		Filter:  regexp.MustCompile(`^Requester\d$`),
+		Jobs:    2,
+	}
+
+	visitor := newConcurrentFailingVisitor("Requester2", "Requester3")
+	generated, err := w.WalkContext(context.Background(), visitor)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error walking Requester2: unable to visit")
+	assert.False(t, generated)
+	assert.Empty(t, visitor.visited, "The walk stops at the first error.")
+	assert.ElementsMatch(t, []string{"Requester2", "Requester3"}, visitor.prepared, "No interface is started after an error.")
+
+	w.KeepGoing = true
+	visitor = newConcurrentFailingVisitor("Requester2", "Requester3")
+	generated, err = w.WalkContext(context.Background(), visitor)
+	require.Error(t, err, "The walk fails when an interface failed.")
+	if assert.IsType(t, Errors{}, err) {
+		assert.Len(t, err.(Errors), 1)
+	}
+	assert.True(t, generated)
+	assert.Equal(t, []string{"Requester3", "Requester4"}, visitor.visited, "The walk goes on past the error.")
+}
@@ -0,0 +1,325 @@
+package mockery
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	Options
+	// How long files must stay unchanged before mocks are generated again,
+	// so that the many writes of saving files are handled at once, 100ms
+	// when zero
+	Debounce time.Duration
+	// How often directories are listed where inotify isn't available, 500ms
+	// when zero
+	PollInterval time.Duration
+	// Called after each round with the mocks generated, those whose
+	// fingerprint changed, and the error of the round, which doesn't stop
+	// the watch
+	OnRound func(files []GeneratedFile, err error)
+}
+
+// Watch generates the mocks of opts like Generate, and then again every time
+// Go files of the walked directories change, until ctx is cancelled. Packages
+// are loaded once, and then again only when their files, or those of the
+// packages they import, change. Only the mocks whose fingerprint changed are
+// generated again.
+func Watch(ctx context.Context, opts WatchOptions) error {
+	if opts.SrcPkg != "" {
+		return errors.New("only directories can be watched, not packages given by import path")
+	}
+	debounce := opts.Debounce
+	if debounce == 0 {
+		debounce = 100 * time.Millisecond
+	}
+	pollInterval := opts.PollInterval
+	if pollInterval == 0 {
+		pollInterval = 500 * time.Millisecond
+	}
+
+	walker, visitor, err := newRun(opts.Options)
+	if err != nil {
+		return err
+	}
+	walker.Parser = NewParser(walker.BuildTags)
+	visitor.watched = &watchState{fingerprints: make(map[string]string)}
+
+	w := newWatcher(pollInterval)
+	defer func() { w.close() }() //nolint:errcheck
+	watchDirs := func() error {
+		for _, dir := range walker.watchedDirs() {
+			// Directories removed since they were listed are reported by
+			// their parent.
+			if err := w.watch(dir); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := watchDirs(); err != nil {
+		if _, polling := w.(*pollWatcher); polling {
+			return err
+		}
+		logf(opts.Logger, "Unable to watch with inotify, polling instead: %s\n", err)
+		w.close() //nolint:errcheck
+		w = newPollWatcher(pollInterval)
+		if err := watchDirs(); err != nil {
+			return err
+		}
+	}
+
+	round := func() {
+		visitor.files = nil
+		var err error
+		if opts.PackageFiles {
+			err = visitor.visitPackages(ctx, walker)
+		} else {
+			_, err = walker.WalkContext(ctx, visitor)
+		}
+		if ctx.Err() == nil && opts.OnRound != nil {
+			opts.OnRound(visitor.files, err)
+		}
+	}
+	round()
+
+	changed := make(map[string]bool)
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case c, ok := <-w.changes():
+			if !ok {
+				return errors.New("stopped watching files")
+			}
+			if visitor.watched.generated(c.path) {
+				continue
+			}
+			if c.dir {
+				changed[c.path] = true
+			} else {
+				changed[filepath.Dir(c.path)] = true
+			}
+			quiet = time.After(debounce)
+		case <-quiet:
+			quiet = nil
+			dirs := make([]string, 0, len(changed))
+			for dir := range changed {
+				dirs = append(dirs, dir)
+			}
+			changed = make(map[string]bool)
+
+			walker.Parser.Invalidate(dirs)
+			if err := watchDirs(); err != nil {
+				return err
+			}
+			round()
+		}
+	}
+}
+
+// watchState is what a watch remembers of the mocks it generated.
+type watchState struct {
+	mu sync.Mutex
+	// The fingerprints of the mocks, by absolute path
+	fingerprints map[string]string
+}
+
+// unchanged tells whether the mock at path was generated with fingerprint
+// and is still there.
+func (s *watchState) unchanged(path, fingerprint string) bool {
+	if path == "" {
+		return false
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	previous, ok := s.fingerprints[path]
+	s.mu.Unlock()
+	if !ok || previous != fingerprint {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// record remembers the fingerprint of the mock written to path. It does
+// nothing on a nil state.
+func (s *watchState) record(path, fingerprint string) {
+	if s == nil || path == "" {
+		return
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fingerprints[path] = fingerprint
+}
+
+// generated tells whether the file at the absolute path is a mock, whose
+// changes are left alone.
+func (s *watchState) generated(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.fingerprints[path]
+	return ok
+}
+
+// change is a file or directory created, written or removed in a watched
+// directory.
+type change struct {
+	path string
+	dir  bool
+}
+
+// watcher reports the changes to the files of the directories it watches,
+// not recursively.
+type watcher interface {
+	watch(dir string) error
+	changes() <-chan change
+	close() error
+}
+
+// watchedEntry tells whether a change to the file or directory name can
+// change the interfaces a walk finds.
+func watchedEntry(name string, dir bool) bool {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return false
+	}
+	return dir || strings.HasSuffix(name, ".go")
+}
+
+// pollWatcher watches directories by listing them periodically, where
+// inotify isn't available.
+type pollWatcher struct {
+	mu sync.Mutex
+	// The watched entries of each directory, by name
+	dirs   map[string]map[string]entryState
+	events chan change
+	done   chan struct{}
+	once   sync.Once
+}
+
+// entryState tells when a file changed. Directories only change when they
+// are created or removed.
+type entryState struct {
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+func newPollWatcher(interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		dirs:   make(map[string]map[string]entryState),
+		events: make(chan change),
+		done:   make(chan struct{}),
+	}
+	go w.poll(interval)
+	return w
+}
+
+func (w *pollWatcher) watch(dir string) error {
+	entries, err := listEntries(dir)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.dirs[dir]; !ok {
+		w.dirs[dir] = entries
+	}
+	return nil
+}
+
+func (w *pollWatcher) changes() <-chan change {
+	return w.events
+}
+
+func (w *pollWatcher) close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollWatcher) poll(interval time.Duration) {
+	defer close(w.events)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		for _, c := range w.scan() {
+			select {
+			case w.events <- c:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// scan lists the watched directories again and returns what changed.
+func (w *pollWatcher) scan() []change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var changes []change
+	for dir, previous := range w.dirs {
+		entries, err := listEntries(dir)
+		if err != nil {
+			// The directory is gone, which its parent reports.
+			delete(w.dirs, dir)
+			continue
+		}
+		for name, entry := range entries {
+			if old, ok := previous[name]; !ok || old != entry {
+				changes = append(changes, change{path: filepath.Join(dir, name), dir: entry.dir})
+			}
+		}
+		for name, old := range previous {
+			if _, ok := entries[name]; !ok {
+				changes = append(changes, change{path: filepath.Join(dir, name), dir: old.dir})
+			}
+		}
+		w.dirs[dir] = entries
+	}
+	return changes
+}
+
+func listEntries(dir string) (map[string]entryState, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]entryState)
+	for _, file := range files {
+		if !watchedEntry(file.Name(), file.IsDir()) {
+			continue
+		}
+		if file.IsDir() {
+			entries[file.Name()] = entryState{dir: true}
+		} else {
+			entries[file.Name()] = entryState{size: file.Size(), modTime: file.ModTime()}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,137 @@
+//go:build linux
+// +build linux
+
+package mockery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// inotifyMask selects the events of a watched directory that can change
+// its Go files: files written, and files or directories created, removed or
+// renamed.
+const inotifyMask = syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE |
+	syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO |
+	syscall.IN_ONLYDIR
+
+// inotifyWatcher watches directories with inotify.
+type inotifyWatcher struct {
+	// The inotify instance, non-blocking so that reads go through the
+	// runtime's poller and are interrupted by closing it
+	file *os.File
+	fd   int
+
+	mu sync.Mutex
+	// The watched directories, by watch descriptor
+	dirs   map[int]string
+	events chan change
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newWatcher watches directories with inotify or, when no inotify instance
+// can be created, by polling them.
+func newWatcher(pollInterval time.Duration) watcher {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return newPollWatcher(pollInterval)
+	}
+
+	w := &inotifyWatcher{
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		fd:     fd,
+		dirs:   make(map[int]string),
+		events: make(chan change),
+		done:   make(chan struct{}),
+	}
+	go w.read()
+	return w
+}
+
+func (w *inotifyWatcher) watch(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dirs[wd] = dir
+	return nil
+}
+
+func (w *inotifyWatcher) changes() <-chan change {
+	return w.events
+}
+
+func (w *inotifyWatcher) close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
+
+func (w *inotifyWatcher) read() {
+	defer close(w.events)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			for _, c := range w.eventChanges(event, name) {
+				select {
+				case w.events <- c:
+				case <-w.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// eventChanges returns the changes an event tells about.
+func (w *inotifyWatcher) eventChanges(event *syscall.InotifyEvent, name string) []change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		// Events were lost, so anything may have changed.
+		changes := make([]change, 0, len(w.dirs))
+		for _, dir := range w.dirs {
+			changes = append(changes, change{path: dir, dir: true})
+		}
+		return changes
+	}
+
+	dir, ok := w.dirs[int(event.Wd)]
+	if event.Mask&syscall.IN_IGNORED != 0 {
+		// The directory was removed, which its parent reports.
+		delete(w.dirs, int(event.Wd))
+		return nil
+	}
+	isDir := event.Mask&syscall.IN_ISDIR != 0
+	if !ok || name == "" || !watchedEntry(name, isDir) {
+		return nil
+	}
+	return []change{{path: filepath.Join(dir, name), dir: isDir}}
+}
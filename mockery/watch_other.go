@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package mockery
+
+import "time"
+
+// newWatcher watches directories by polling them, as inotify is only
+// available on Linux.
+func newWatcher(pollInterval time.Duration) watcher {
+	return newPollWatcher(pollInterval)
+}
@@ -0,0 +1,301 @@
+package mockery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type watchRound struct {
+	files []GeneratedFile
+	err   error
+}
+
+func (r watchRound) names() []string {
+	names := make([]string, len(r.files))
+	for i, file := range r.files {
+		names[i] = file.Interface.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestWatch(t *testing.T) {
+	pkgA := tempModulePath + "/a"
+	dir := tempModule(t, map[string]string{
+		"a/a.go": "package a\n\ntype A interface {\n\tDo()\n}\n",
+		"b/b.go": fmt.Sprintf("package b\n\nimport %q\n\ntype B interface {\n\ta.A\n}\n\ntype C interface {\n\tClose() error\n}\n", pkgA),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rounds := make(chan watchRound)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, WatchOptions{
+			Options: Options{
+				Dir:       dir,
+				Recursive: true,
+				Osp:       &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+			},
+			Debounce: 50 * time.Millisecond,
+			OnRound: func(files []GeneratedFile, err error) {
+				rounds <- watchRound{files: files, err: err}
+			},
+		})
+	}()
+	next := func() watchRound {
+		select {
+		case r := <-rounds:
+			return r
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "No round of generation.")
+			return watchRound{}
+		}
+	}
+
+	r := next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"A", "B", "C"}, r.names())
+
+	writeTempFile(t, filepath.Join(dir, "a", "a.go"), "package a\n\ntype A interface {\n\tDo()\n\tUndo()\n}\n")
+	r = next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"A", "B"}, r.names(), "Mocks of interfaces embedding a changed one are generated again.")
+	mock, err := ioutil.ReadFile(filepath.Join(dir, "mocks", "B.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mock), "func (_m *B) Undo()")
+
+	writeTempFile(t, filepath.Join(dir, "b", "b.go"), fmt.Sprintf("package b\n\nimport %q\n\n// B does.\ntype B interface {\n\ta.A\n}\n\ntype C interface {\n\tClose() error\n}\n", pkgA))
+	r = next()
+	require.NoError(t, r.err)
+	assert.Empty(t, r.names(), "Mocks whose fingerprint is the same aren't generated again.")
+
+	writeTempFile(t, filepath.Join(dir, "b", "d.go"), "package b\n\ntype D interface {\n\tOpen() error\n}\n")
+	r = next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"D"}, r.names())
+
+	writeTempFile(t, filepath.Join(dir, "b", "d.go"), "package b\n\ntype D interface {\n\tOpen() error\n")
+	r = next()
+	assert.Error(t, r.err, "Errors are reported and the watch goes on.")
+
+	writeTempFile(t, filepath.Join(dir, "b", "d.go"), "package b\n\ntype D interface {\n\tOpen(name string) error\n}\n")
+	r = next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"D"}, r.names(), "Packages that failed to load are loaded again.")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchCollisions(t *testing.T) {
+	dir := tempModule(t, map[string]string{
+		"a/store/store.go": "package store\n\ntype Store interface {\n\tGet() string\n}\n",
+		"b/store/store.go": "package store\n\ntype Store interface {\n\tPut(v string)\n}\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rounds := make(chan watchRound)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, WatchOptions{
+			Options: Options{
+				Dir:          dir,
+				Recursive:    true,
+				Disambiguate: DisambiguatePrefix,
+				Osp:          &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+			},
+			Debounce: 50 * time.Millisecond,
+			OnRound: func(files []GeneratedFile, err error) {
+				rounds <- watchRound{files: files, err: err}
+			},
+		})
+	}()
+	paths := func() []string {
+		var r watchRound
+		select {
+		case r = <-rounds:
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "No round of generation.")
+		}
+		require.NoError(t, r.err)
+		var paths []string
+		for _, file := range r.files {
+			paths = append(paths, filepath.Base(file.Path))
+		}
+		return paths
+	}
+
+	assert.Equal(t, []string{"AStoreStore.go", "BStoreStore.go"}, paths())
+
+	writeTempFile(t, filepath.Join(dir, "a", "queue", "queue.go"), "package queue\n\ntype Queue interface {\n\tPush()\n}\n")
+	writeTempFile(t, filepath.Join(dir, "b", "queue", "queue.go"), "package queue\n\ntype Queue interface {\n\tPop()\n}\n")
+	assert.Equal(t, []string{"AQueueQueue.go", "BQueueQueue.go"}, paths(), "Earlier collisions are still told apart.")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchDependentPackage(t *testing.T) {
+	pkgA := tempModulePath + "/a"
+	dir := tempModule(t, map[string]string{
+		"a/a.go": "package a\n\ntype A interface {\n\tDo()\n}\n",
+		"b/b.go": fmt.Sprintf("package b\n\nimport %q\n\ntype B interface {\n\ta.A\n}\n\ntype C interface {\n\tClose() error\n}\n", pkgA),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rounds := make(chan watchRound)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, WatchOptions{
+			Options: Options{
+				Dir:       dir,
+				Recursive: true,
+				KeepGoing: true,
+				Osp:       &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+			},
+			Debounce: 50 * time.Millisecond,
+			OnRound: func(files []GeneratedFile, err error) {
+				rounds <- watchRound{files: files, err: err}
+			},
+		})
+	}()
+	next := func() watchRound {
+		select {
+		case r := <-rounds:
+			return r
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "No round of generation.")
+			return watchRound{}
+		}
+	}
+
+	r := next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"A", "B", "C"}, r.names())
+
+	writeTempFile(t, filepath.Join(dir, "a", "a.go"), "package a\n\ntype A interface {\n\tDo(\n")
+	r = next()
+	assert.Error(t, r.err, "Packages importing a broken one fail too.")
+
+	writeTempFile(t, filepath.Join(dir, "a", "a.go"), "package a\n\ntype A interface {\n\tDo()\n\tUndo()\n}\n")
+	r = next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"A", "B"}, r.names(), "Packages that failed because of an import are loaded again once it's fixed.")
+	mock, err := ioutil.ReadFile(filepath.Join(dir, "mocks", "B.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mock), "func (_m *B) Undo()")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchUnderlyingType(t *testing.T) {
+	pkgA := tempModulePath + "/a"
+	dir := tempModule(t, map[string]string{
+		"a/a.go": "package a\n\ntype R struct{}\n",
+		"b/b.go": fmt.Sprintf("package b\n\nimport %q\n\ntype Getter interface {\n\tGet() a.R\n}\n", pkgA),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rounds := make(chan watchRound)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, WatchOptions{
+			Options: Options{
+				Dir:       dir,
+				Recursive: true,
+				Osp:       &FileOutputStreamProvider{BaseDir: filepath.Join(dir, "mocks")},
+			},
+			Debounce: 50 * time.Millisecond,
+			OnRound: func(files []GeneratedFile, err error) {
+				rounds <- watchRound{files: files, err: err}
+			},
+		})
+	}()
+	next := func() watchRound {
+		select {
+		case r := <-rounds:
+			return r
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "No round of generation.")
+			return watchRound{}
+		}
+	}
+
+	r := next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"Getter"}, r.names())
+
+	writeTempFile(t, filepath.Join(dir, "a", "a.go"), "package a\n\ntype R map[string]int\n")
+	r = next()
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{"Getter"}, r.names(), "Mocks depend on whether the types they return can be nil.")
+	mock, err := ioutil.ReadFile(filepath.Join(dir, "mocks", "Getter.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mock), "if ret.Get(0) != nil")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchSrcPkg(t *testing.T) {
+	err := Watch(context.Background(), WatchOptions{Options: Options{SrcPkg: "net/http"}})
+	assert.Error(t, err)
+}
+
+func TestPollWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w := newPollWatcher(10 * time.Millisecond)
+	defer w.close() //nolint:errcheck
+	require.NoError(t, w.watch(dir))
+
+	next := func() change {
+		select {
+		case c := <-w.changes():
+			return c
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "No change reported.")
+			return change{}
+		}
+	}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".a.go.swp"), nil, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644))
+	assert.Equal(t, change{path: filepath.Join(dir, "a.go")}, next(), "Only Go files are watched.")
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	assert.Equal(t, change{path: filepath.Join(dir, "sub"), dir: true}, next())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "a.go")))
+	assert.Equal(t, change{path: filepath.Join(dir, "a.go")}, next())
+}
+
+func TestParserInvalidate(t *testing.T) {
+	parser := NewParser(nil)
+	require.NoError(t, parser.Parse(getFixturePath("testdata", "collision", "a", "store", "store.go")))
+	require.NoError(t, parser.Parse(getFixturePath("testdata", "collision", "c", "store.go")))
+	require.NoError(t, parser.Load())
+	require.Len(t, parser.Interfaces(), 2)
+
+	parser.Invalidate([]string{getFixturePath("testdata", "collision", "a")})
+	ifaces := parser.Interfaces()
+	require.Len(t, ifaces, 1, "Packages under the directory are dropped.")
+	assert.Equal(t, collisionPkg+"/c", ifaces[0].QualifiedName)
+
+	require.NoError(t, parser.Parse(getFixturePath("testdata", "collision", "a", "store", "store.go")))
+	assert.Len(t, parser.pending, 1, "Only the dropped package is loaded again.")
+	require.NoError(t, parser.Load())
+	assert.Len(t, parser.Interfaces(), 2)
+}